@@ -35,3 +35,42 @@ func NewAnimation(first, last, step int, speed float32) *Animation {
 		first,
 	}
 }
+
+// FrameAnimation plays a list of arbitrary frames, each of which is shown
+// for its own number of ticks
+type FrameAnimation struct {
+	Frames       []int
+	Durations    []float32 // how many ticks each frame is shown
+	frameCounter float32
+	index        int
+}
+
+func (a *FrameAnimation) Update() {
+	if len(a.Frames) == 0 {
+		return
+	}
+	a.frameCounter -= 1.0
+	for a.frameCounter < 0.0 {
+		a.index = (a.index + 1) % len(a.Frames)
+		a.frameCounter += a.Durations[a.index]
+		if a.Durations[a.index] <= 0.0 {
+			// zero length frames would never let the loop finish
+			a.frameCounter = 0.0
+		}
+	}
+}
+
+func (a *FrameAnimation) Frame() int {
+	return a.Frames[a.index]
+}
+
+func NewFrameAnimation(frames []int, durations []float32) *FrameAnimation {
+	a := &FrameAnimation{
+		Frames:    frames,
+		Durations: durations,
+	}
+	if len(durations) > 0 {
+		a.frameCounter = durations[0]
+	}
+	return a
+}
@@ -0,0 +1,73 @@
+package assets
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"os"
+)
+
+//go:embed images maps
+var embedded embed.FS
+
+// Embedded returns the assets built into the binary. Paths start at the
+// assets directory, such as "maps/world.json".
+func Embedded() fs.FS {
+	return embedded
+}
+
+// Overlay is a file system that serves files from the mods file system
+// when they are there and from the base file system otherwise, so a mod
+// only has to ship the files it changes
+type Overlay struct {
+	base fs.FS
+	mods fs.FS
+}
+
+func NewOverlay(base, mods fs.FS) *Overlay {
+	return &Overlay{
+		base: base,
+		mods: mods,
+	}
+}
+
+func (o *Overlay) Open(name string) (fs.File, error) {
+	file, err := o.mods.Open(name)
+	if err == nil {
+		return file, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return o.base.Open(name)
+}
+
+// Load returns the base assets, usually the embedded ones, overridden by
+// the files in modsDir if it isn't empty
+func Load(base fs.FS, modsDir string) (fs.FS, error) {
+	if modsDir == "" {
+		return base, nil
+	}
+
+	info, err := os.Stat(modsDir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: modsDir, Err: errors.New("not a directory")}
+	}
+	return NewOverlay(base, os.DirFS(modsDir)), nil
+}
+
+// LoadError returns err as an *fs.PathError naming the asset that failed
+// to load, unless it already names a file. The file named is then the one
+// that actually failed when loading one asset loads others.
+func LoadError(name string, err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return err
+	}
+	return &fs.PathError{Op: "load", Path: name, Err: err}
+}
+
+var _ fs.FS = (*Overlay)(nil)
@@ -0,0 +1,402 @@
+package assets
+
+import (
+	"EndlessJourney/tilemap"
+	"EndlessJourney/tileset"
+	"bytes"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// Manager loads assets by their path in a file system and shares them
+// between everyone who asks. Every Image, Tileset, Map, Font or Sound call
+// takes a reference that the matching Release call gives back, and an
+// asset is freed once nothing references it. Assets loaded on behalf of
+// another, such as the images of a tileset, are released along with it.
+// Failed loads return an *fs.PathError naming the file that failed.
+//
+// Assets can be loaded from several goroutines at once. An asset asked for
+// while another goroutine loads it waits for that load instead of loading
+// it twice.
+type Manager struct {
+	fsys     fs.FS
+	mu       sync.Mutex
+	images   map[string]*entry[*ebiten.Image]
+	tilesets map[string]*entry[tileset.Tileset]
+	maps     map[string]*entry[*tilemap.TilemapJSON]
+	fonts    map[string]*entry[*text.GoTextFaceSource]
+	sounds   map[string]*entry[[]byte]
+	// frees what Reload replaced, once nothing uses it anymore
+	replaced []func()
+}
+
+type entry[T any] struct {
+	value T
+	err   error
+	refs  int
+	deps  *dependencies
+	// closed once the asset is loaded
+	ready chan struct{}
+}
+
+func NewManager(fsys fs.FS) *Manager {
+	return &Manager{
+		fsys:     fsys,
+		images:   make(map[string]*entry[*ebiten.Image]),
+		tilesets: make(map[string]*entry[tileset.Tileset]),
+		maps:     make(map[string]*entry[*tilemap.TilemapJSON]),
+		fonts:    make(map[string]*entry[*text.GoTextFaceSource]),
+		sounds:   make(map[string]*entry[[]byte]),
+	}
+}
+
+// Open opens a file of the underlying file system, so the manager can be
+// used wherever a file system is
+func (m *Manager) Open(name string) (fs.File, error) {
+	return m.fsys.Open(name)
+}
+
+func (m *Manager) Image(name string) (*ebiten.Image, error) {
+	return acquire(m, m.images, name, func(fsys fs.FS, name string) (*ebiten.Image, error) {
+		img, _, err := ebitenutil.NewImageFromFileSystem(fsys, name)
+		return img, err
+	})
+}
+
+func (m *Manager) ReleaseImage(name string) {
+	release(m, m.images, name, func(img *ebiten.Image) {
+		img.Deallocate()
+	})
+}
+
+func (m *Manager) Tileset(name string) (tileset.Tileset, error) {
+	return acquire(m, m.tilesets, name, tileset.NewTileset)
+}
+
+func (m *Manager) ReleaseTileset(name string) {
+	release(m, m.tilesets, name, nil)
+}
+
+// Map loads a map. Its tilesets and images, once generated, belong to the
+// map and are released with it. Maps are shared, so changes to one are
+// seen by everyone holding it.
+func (m *Manager) Map(name string) (*tilemap.TilemapJSON, error) {
+	return acquire(m, m.maps, name, tilemap.NewTilemap)
+}
+
+func (m *Manager) ReleaseMap(name string) {
+	release(m, m.maps, name, nil)
+}
+
+func (m *Manager) Font(name string) (*text.GoTextFaceSource, error) {
+	return acquire(m, m.fonts, name, func(fsys fs.FS, name string) (*text.GoTextFaceSource, error) {
+		contents, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		return text.NewGoTextFaceSource(bytes.NewReader(contents))
+	})
+}
+
+func (m *Manager) ReleaseFont(name string) {
+	release(m, m.fonts, name, nil)
+}
+
+// Sound returns the undecoded contents of a sound file, for the audio
+// package to decode in the format it is in
+func (m *Manager) Sound(name string) ([]byte, error) {
+	return acquire(m, m.sounds, name, fs.ReadFile)
+}
+
+func (m *Manager) ReleaseSound(name string) {
+	release(m, m.sounds, name, nil)
+}
+
+// Reload loads the file at name again into every asset cached from it,
+// then reloads the tilesets and maps depending on a reloaded asset. Images
+// that keep their size are updated in place, so everything drawing them
+// shows the change. Other assets get a new value that has to be fetched
+// again. What they replace stays usable until ReleaseReplaced is called,
+// so nothing is freed from under whoever still holds it. An asset that
+// fails to load keeps its old value. Reload must not be called while
+// assets are loading on other goroutines. It returns the names of the
+// cached assets it reloaded, even when it fails partway.
+func (m *Manager) Reload(name string) ([]string, error) {
+	name = path.Clean(name)
+	reloaded := make(nameSet)
+
+	if cached, ok := lookup(m, m.images, name); ok {
+		img, _, err := ebitenutil.NewImageFromFileSystem(m.fsys, name)
+		if err != nil {
+			return reloaded.names(), LoadError(name, err)
+		}
+		if img.Bounds().Size() == cached.value.Bounds().Size() {
+			cached.value.Clear()
+			cached.value.DrawImage(img, nil)
+			img.Deallocate()
+		} else {
+			//whoever holds the old image keeps it until they fetch again
+			old := cached.value
+			m.replace(old.Deallocate)
+			cached.value = img
+		}
+		//what depends on the image is reloaded either way, as maps cache
+		//what they drew from it
+		reloaded[name] = true
+	}
+
+	for key, cached := range snapshot(m, m.tilesets) {
+		if key == name || cached.deps.dependsOn(reloaded) {
+			err := reload(m, cached, key, tileset.NewTileset)
+			if err != nil {
+				return reloaded.names(), err
+			}
+			reloaded[key] = true
+		}
+	}
+
+	for key, cached := range snapshot(m, m.maps) {
+		if key == name || cached.deps.dependsOn(reloaded) {
+			err := reload(m, cached, key, tilemap.NewTilemap)
+			if err != nil {
+				return reloaded.names(), err
+			}
+			reloaded[key] = true
+		}
+	}
+
+	if cached, ok := lookup(m, m.fonts, name); ok {
+		contents, err := fs.ReadFile(m.fsys, name)
+		if err != nil {
+			return reloaded.names(), err
+		}
+		source, err := text.NewGoTextFaceSource(bytes.NewReader(contents))
+		if err != nil {
+			return reloaded.names(), LoadError(name, err)
+		}
+		cached.value = source
+		reloaded[name] = true
+	}
+
+	if cached, ok := lookup(m, m.sounds, name); ok {
+		contents, err := fs.ReadFile(m.fsys, name)
+		if err != nil {
+			return reloaded.names(), err
+		}
+		cached.value = contents
+		reloaded[name] = true
+	}
+
+	return reloaded.names(), nil
+}
+
+// nameSet is a set of asset names
+type nameSet map[string]bool
+
+func (n nameSet) names() []string {
+	names := make([]string, 0, len(n))
+	for name := range n {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ReleaseReplaced frees the assets and references Reload replaced, to be
+// called once everything holding the old ones has fetched the new ones
+func (m *Manager) ReleaseReplaced() {
+	m.mu.Lock()
+	replaced := m.replaced
+	m.replaced = nil
+	m.mu.Unlock()
+
+	for _, free := range replaced {
+		free()
+	}
+}
+
+// replace keeps free to be called by ReleaseReplaced
+func (m *Manager) replace(free func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.replaced = append(m.replaced, free)
+}
+
+// acquire returns the cached asset with a new reference, loading it if no
+// one holds it yet. The lock isn't held while loading, since loading an
+// asset acquires the ones it depends on.
+func acquire[T any](m *Manager, cache map[string]*entry[T], name string, load func(fsys fs.FS, name string) (T, error)) (T, error) {
+	name = path.Clean(name)
+	m.mu.Lock()
+	if cached, ok := cache[name]; ok {
+		cached.refs++
+		m.mu.Unlock()
+		<-cached.ready
+		return cached.value, cached.err
+	}
+	cached := &entry[T]{
+		refs:  1,
+		ready: make(chan struct{}),
+	}
+	cache[name] = cached
+	m.mu.Unlock()
+
+	deps := &dependencies{manager: m}
+	value, err := load(deps, name)
+	if err != nil {
+		deps.release()
+		m.mu.Lock()
+		//the entry may already be gone if it was released during the load
+		if cache[name] == cached {
+			delete(cache, name)
+		}
+		m.mu.Unlock()
+		cached.err = LoadError(name, err)
+		close(cached.ready)
+		return value, cached.err
+	}
+	cached.value = value
+	cached.deps = deps
+	close(cached.ready)
+	return value, nil
+}
+
+// release drops a reference to a cached asset, freeing it and what it
+// depends on once no references are left
+func release[T any](m *Manager, cache map[string]*entry[T], name string, free func(T)) {
+	name = path.Clean(name)
+	m.mu.Lock()
+	cached, ok := cache[name]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+	cached.refs--
+	if cached.refs > 0 {
+		m.mu.Unlock()
+		return
+	}
+	delete(cache, name)
+	m.mu.Unlock()
+
+	//released while still loading, free whatever the load ends up with
+	<-cached.ready
+	if cached.err != nil {
+		return
+	}
+	if free != nil {
+		free(cached.value)
+	}
+	cached.deps.release()
+}
+
+func lookup[T any](m *Manager, cache map[string]*entry[T], name string) (*entry[T], bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cached, ok := cache[name]
+	return cached, ok
+}
+
+// snapshot copies a cache to go through without holding the lock
+func snapshot[T any](m *Manager, cache map[string]*entry[T]) map[string]*entry[T] {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return maps.Clone(cache)
+}
+
+// reload replaces the value of a cached asset with a freshly loaded one,
+// swapping the references to what it depends on as well. The old
+// references are given back by ReleaseReplaced.
+func reload[T any](m *Manager, cached *entry[T], name string, load func(fsys fs.FS, name string) (T, error)) error {
+	deps := &dependencies{manager: m}
+	value, err := load(deps, name)
+	if err != nil {
+		deps.release()
+		return LoadError(name, err)
+	}
+
+	m.replace(cached.deps.release)
+	cached.value = value
+	cached.deps = deps
+	return nil
+}
+
+// dependencies is the file system an asset is loaded through. Images and
+// tilesets it loads come from the manager, and the references taken for
+// them are given back when the asset is released. Each is referenced once
+// however often it is loaded, as maps load their tilesets every time they
+// are shown.
+type dependencies struct {
+	manager  *Manager
+	images   []string
+	tilesets []string
+}
+
+func (d *dependencies) Open(name string) (fs.File, error) {
+	return d.manager.Open(name)
+}
+
+func (d *dependencies) Image(name string) (*ebiten.Image, error) {
+	img, err := d.manager.Image(name)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(d.images, path.Clean(name)) {
+		d.manager.ReleaseImage(name)
+	} else {
+		d.images = append(d.images, path.Clean(name))
+	}
+	return img, nil
+}
+
+func (d *dependencies) Tileset(name string) (tileset.Tileset, error) {
+	ts, err := d.manager.Tileset(name)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(d.tilesets, path.Clean(name)) {
+		d.manager.ReleaseTileset(name)
+	} else {
+		d.tilesets = append(d.tilesets, path.Clean(name))
+	}
+	return ts, nil
+}
+
+// dependsOn reports whether any of the named assets were loaded through d
+func (d *dependencies) dependsOn(names nameSet) bool {
+	for _, name := range d.images {
+		if names[name] {
+			return true
+		}
+	}
+	for _, name := range d.tilesets {
+		if names[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *dependencies) release() {
+	for _, name := range d.tilesets {
+		d.manager.ReleaseTileset(name)
+	}
+	for _, name := range d.images {
+		d.manager.ReleaseImage(name)
+	}
+	d.images = nil
+	d.tilesets = nil
+}
+
+var _ tileset.ImageFS = (*Manager)(nil)
+var _ tilemap.TilesetFS = (*Manager)(nil)
+var _ tileset.ImageFS = (*dependencies)(nil)
+var _ tilemap.TilesetFS = (*dependencies)(nil)
@@ -0,0 +1,308 @@
+package assets
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io/fs"
+	"runtime"
+	"slices"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+// pngFile returns a file holding a blank png of the given size
+func pngFile(t *testing.T, w, h int) *fstest.MapFile {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return &fstest.MapFile{Data: buf.Bytes()}
+}
+
+// loadText is a loader that needs no gpu, for testing the cache itself
+func loadText(fsys fs.FS, name string) (string, error) {
+	contents, err := fs.ReadFile(fsys, name)
+	return string(contents), err
+}
+
+// cachedNames returns the names in a cache, sorted
+func cachedNames[T any](m *Manager, cache map[string]*entry[T]) []string {
+	names := make([]string, 0)
+	for name := range snapshot(m, cache) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestAcquireRelease(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt":     {Data: []byte("a")},
+		"dir/b.txt": {Data: []byte("b")},
+	}
+
+	tests := []struct {
+		name string
+		// "+name" acquires the asset, "-name" releases it
+		ops    []string
+		freed  []string
+		cached []string
+	}{
+		{
+			name:   "held",
+			ops:    []string{"+a.txt"},
+			cached: []string{"a.txt"},
+		},
+		{
+			name:  "released",
+			ops:   []string{"+a.txt", "-a.txt"},
+			freed: []string{"a"},
+		},
+		{
+			name:   "shared",
+			ops:    []string{"+a.txt", "+a.txt", "-a.txt"},
+			cached: []string{"a.txt"},
+		},
+		{
+			name:  "shared and released by everyone",
+			ops:   []string{"+a.txt", "+a.txt", "-a.txt", "-a.txt"},
+			freed: []string{"a"},
+		},
+		{
+			name:  "released once too often",
+			ops:   []string{"+a.txt", "-a.txt", "-a.txt"},
+			freed: []string{"a"},
+		},
+		{
+			name:  "never acquired",
+			ops:   []string{"-a.txt"},
+			freed: nil,
+		},
+		{
+			name:  "same file under other names",
+			ops:   []string{"+dir/../a.txt", "+./a.txt", "-a.txt", "-dir/./../a.txt"},
+			freed: []string{"a"},
+		},
+		{
+			name:   "two assets",
+			ops:    []string{"+a.txt", "+dir/b.txt", "-a.txt"},
+			freed:  []string{"a"},
+			cached: []string{"dir/b.txt"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := NewManager(fsys)
+			cache := make(map[string]*entry[string])
+			freed := make([]string, 0)
+			for _, op := range test.ops {
+				name := op[1:]
+				if op[0] == '+' {
+					if _, err := acquire(m, cache, name, loadText); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				release(m, cache, name, func(value string) {
+					freed = append(freed, value)
+				})
+			}
+
+			if !slices.Equal(freed, test.freed) {
+				t.Errorf("freed %v, want %v", freed, test.freed)
+			}
+			if names := cachedNames(m, cache); !slices.Equal(names, test.cached) {
+				t.Errorf("cached %v, want %v", names, test.cached)
+			}
+		})
+	}
+}
+
+func TestFailedLoad(t *testing.T) {
+	fsys := fstest.MapFS{}
+	m := NewManager(fsys)
+	cache := make(map[string]*entry[string])
+
+	_, err := acquire(m, cache, "missing.txt", loadText)
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) || pathErr.Path != "missing.txt" {
+		t.Fatalf("acquire error = %v, want a path error for missing.txt", err)
+	}
+	if names := cachedNames(m, cache); len(names) != 0 {
+		t.Fatalf("failed load left %v cached", names)
+	}
+
+	// the failure isn't remembered, so the file loads once it is there
+	fsys["missing.txt"] = &fstest.MapFile{Data: []byte("found")}
+	value, err := acquire(m, cache, "missing.txt", loadText)
+	if err != nil || value != "found" {
+		t.Errorf("acquire after adding the file = %q, %v, want found", value, err)
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.png":     pngFile(t, 4, 4),
+		"dir/b.png": pngFile(t, 4, 4),
+	}
+
+	tests := []struct {
+		name   string
+		images []string
+		want   []string
+	}{
+		{"one", []string{"a.png"}, []string{"a.png"}},
+		{"loaded again", []string{"a.png", "a.png", "a.png"}, []string{"a.png"}},
+		{"other spelling", []string{"a.png", "./a.png", "dir/../a.png"}, []string{"a.png"}},
+		{"two", []string{"a.png", "dir/b.png", "a.png"}, []string{"a.png", "dir/b.png"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := NewManager(fsys)
+			deps := &dependencies{manager: m}
+			for _, name := range test.images {
+				if _, err := deps.Image(name); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if !slices.Equal(deps.images, test.want) {
+				t.Errorf("dependencies hold %v, want %v", deps.images, test.want)
+			}
+			for _, name := range test.want {
+				if cached, _ := lookup(m, m.images, name); cached.refs != 1 {
+					t.Errorf("%s has %d references, want 1", name, cached.refs)
+				}
+			}
+
+			deps.release()
+			if names := cachedNames(m, m.images); len(names) != 0 {
+				t.Errorf("release left %v cached", names)
+			}
+		})
+	}
+}
+
+func TestReleaseWhileLoading(t *testing.T) {
+	m := NewManager(fstest.MapFS{"a.txt": {Data: []byte("a")}})
+	cache := make(map[string]*entry[string])
+	started, finish := make(chan struct{}), make(chan struct{})
+	load := func(fsys fs.FS, name string) (string, error) {
+		close(started)
+		<-finish
+		return loadText(fsys, name)
+	}
+
+	loaded := make(chan struct{})
+	go func() {
+		acquire(m, cache, "a.txt", load)
+		close(loaded)
+	}()
+	<-started
+
+	freed := make(chan string, 2)
+	released := make(chan struct{})
+	go func() {
+		release(m, cache, "a.txt", func(value string) {
+			freed <- value
+		})
+		close(released)
+	}()
+	// let the release drop the entry before the load finishes
+	for {
+		if _, ok := lookup(m, cache, "a.txt"); !ok {
+			break
+		}
+		runtime.Gosched()
+	}
+	close(finish)
+	<-loaded
+	<-released
+
+	close(freed)
+	values := make([]string, 0)
+	for value := range freed {
+		values = append(values, value)
+	}
+	if !slices.Equal(values, []string{"a"}) {
+		t.Errorf("freed %v, want the loaded value once", values)
+	}
+}
+
+func TestReload(t *testing.T) {
+	fsys := fstest.MapFS{
+		"maps/a.json": {Data: []byte(`{
+			"width": 1, "height": 1, "tilewidth": 16, "tileheight": 16,
+			"layers": [], "tilesets": [{"firstgid": 1, "source": "tiles.json"}]
+		}`)},
+		"maps/tiles.json": {Data: []byte(`{
+			"image": "tiles.png", "imagewidth": 16, "imageheight": 16,
+			"tilewidth": 16, "tileheight": 16, "tilecount": 1, "columns": 1
+		}`)},
+		"maps/tiles.png": pngFile(t, 16, 16),
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		size     int
+		reloaded []string
+		newMap   bool
+	}{
+		{"image in place", "maps/tiles.png", 16, []string{"maps/a.json", "maps/tiles.json", "maps/tiles.png"}, true},
+		{"image resized", "maps/tiles.png", 32, []string{"maps/a.json", "maps/tiles.json", "maps/tiles.png"}, true},
+		{"tileset", "maps/tiles.json", 16, []string{"maps/a.json", "maps/tiles.json"}, true},
+		{"map", "maps/a.json", 16, []string{"maps/a.json"}, true},
+		{"not loaded", "maps/other.json", 16, []string{}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fsys["maps/tiles.png"] = pngFile(t, 16, 16)
+			m := NewManager(fsys)
+			tm, err := m.Map("maps/a.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tm.GenTilesets(); err != nil {
+				t.Fatal(err)
+			}
+			img, _ := lookup(m, m.images, "maps/tiles.png")
+			oldImage := img.value
+
+			fsys["maps/tiles.png"] = pngFile(t, test.size, test.size)
+			reloaded, err := m.Reload(test.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(reloaded, test.reloaded) {
+				t.Errorf("Reload reloaded %v, want %v", reloaded, test.reloaded)
+			}
+
+			cached, _ := lookup(m, m.maps, "maps/a.json")
+			if (cached.value != tm) != test.newMap {
+				t.Errorf("map replaced = %v, want %v", cached.value != tm, test.newMap)
+			}
+			if img, _ := lookup(m, m.images, "maps/tiles.png"); (img.value != oldImage) != (test.size != 16) {
+				t.Errorf("image replaced = %v, want it replaced only when resized", img.value != oldImage)
+			}
+
+			// the old map keeps its tileset and image until the scene is done
+			// with it, while the reloaded map has not generated its own yet
+			if names := cachedNames(m, m.tilesets); !slices.Equal(names, []string{"maps/tiles.json"}) {
+				t.Errorf("before ReleaseReplaced tilesets %v are cached, want the old one kept", names)
+			}
+			m.ReleaseReplaced()
+			want := []string{"maps/tiles.json"}
+			if test.newMap {
+				want = []string{}
+			}
+			if names := cachedNames(m, m.tilesets); !slices.Equal(names, want) {
+				t.Errorf("after ReleaseReplaced tilesets %v are cached, want %v", names, want)
+			}
+		})
+	}
+}
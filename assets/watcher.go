@@ -0,0 +1,88 @@
+package assets
+
+import (
+	"io/fs"
+	"sort"
+	"time"
+)
+
+// Watcher polls file systems for files that changed, so that assets can
+// be reloaded while the game runs. Names are reported the same for every
+// file system, as the file systems of an Overlay share them.
+type Watcher struct {
+	roots    []fs.FS
+	modTimes map[watchedFile]time.Time
+}
+
+type watchedFile struct {
+	root int
+	name string
+}
+
+// NewWatcher starts watching the file systems, files changed from now on
+// are reported
+func NewWatcher(roots ...fs.FS) (*Watcher, error) {
+	w := &Watcher{
+		roots: roots,
+	}
+	modTimes, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+	w.modTimes = modTimes
+	return w, nil
+}
+
+// Changed returns the files that were modified, added or removed since
+// the last call
+func (w *Watcher) Changed() ([]string, error) {
+	modTimes, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]bool)
+	for file, modTime := range modTimes {
+		if old, ok := w.modTimes[file]; !ok || !old.Equal(modTime) {
+			changed[file.name] = true
+		}
+	}
+	//a removed mod file brings back the one it replaced
+	for file := range w.modTimes {
+		if _, ok := modTimes[file]; !ok {
+			changed[file.name] = true
+		}
+	}
+	w.modTimes = modTimes
+
+	names := make([]string, 0, len(changed))
+	for name := range changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (w *Watcher) scan() (map[watchedFile]time.Time, error) {
+	modTimes := make(map[watchedFile]time.Time)
+	for root, fsys := range w.roots {
+		err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			modTimes[watchedFile{root, name}] = info.ModTime()
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return modTimes, nil
+}
@@ -2,26 +2,114 @@ package camera
 
 import "math"
 
+// Camera is the offset the world is drawn at. X and Y are kept to whole
+// pixels so that pixel art doesn't shimmer as the view glides.
 type Camera struct {
 	X, Y float64
+	// fraction of the remaining way to the target the view covers every
+	// tick, from 0 to 1. 1 snaps to the target, lower values ease after it.
+	Smoothing float64
+	// size in pixels of the area around the middle of the screen the
+	// target can move in without the view following
+	DeadZoneWidth, DeadZoneHeight float64
+	// how far in pixels the view leads the target in the direction it is
+	// moving
+	LookAhead float64
+
+	// position before rounding
+	x, y float64
+	// point the view centers on, dragged along by the target once it
+	// reaches the edge of the dead zone
+	focusX, focusY float64
+	// current look ahead offset, easing towards the direction of movement
+	lookX, lookY float64
+	following    bool
 }
 
 func NewCamera(x, y float64) *Camera {
 	return &Camera{
-		X: x,
-		Y: y,
+		X:         x,
+		Y:         y,
+		Smoothing: 1.0,
+		x:         x,
+		y:         y,
 	}
 }
 
+// FollowTarget moves the view towards the target, a point in the world
+// the view keeps in the middle of the screen. The first call after
+// NewCamera or Reset jumps straight to the target.
 func (c *Camera) FollowTarget(targetX, targetY, screenWidth, screenHeight float64) {
-	c.X = -targetX + screenWidth/2.0
-	c.Y = -targetY + screenHeight/2.0
+	if !c.following {
+		c.following = true
+		c.focusX, c.focusY = targetX, targetY
+		c.lookX, c.lookY = 0, 0
+		c.x = -targetX + screenWidth/2.0
+		c.y = -targetY + screenHeight/2.0
+		c.round()
+		return
+	}
+
+	lastX, lastY := c.focusX, c.focusY
+	c.focusX = drag(c.focusX, targetX, c.DeadZoneWidth/2.0)
+	c.focusY = drag(c.focusY, targetY, c.DeadZoneHeight/2.0)
+
+	//looking ahead only while the target drags the view, so small moves
+	//inside the dead zone don't swing it around
+	c.lookX = lerp(c.lookX, c.LookAhead*sign(c.focusX-lastX), c.Smoothing)
+	c.lookY = lerp(c.lookY, c.LookAhead*sign(c.focusY-lastY), c.Smoothing)
+
+	c.x = lerp(c.x, -(c.focusX+c.lookX)+screenWidth/2.0, c.Smoothing)
+	c.y = lerp(c.y, -(c.focusY+c.lookY)+screenHeight/2.0, c.Smoothing)
+	c.round()
+}
+
+// Reset makes the next FollowTarget jump to the target instead of easing
+// there, for when the target is moved somewhere else entirely
+func (c *Camera) Reset() {
+	c.following = false
+}
+
+// Constrain keeps the view inside the pixel area from min to max, which
+// may start at negative coordinates on infinite maps
+func (c *Camera) Constrain(minX, minY, maxX, maxY, screenWidth, screenHeight float64) {
+	c.X = math.Min(c.X, -minX)
+	c.Y = math.Min(c.Y, -minY)
+
+	c.X = math.Max(c.X, screenWidth-maxX)
+	c.Y = math.Max(c.Y, screenHeight-maxY)
+
+	//ease away from the edge from where the view stopped
+	c.x = math.Max(math.Min(c.x, -minX), screenWidth-maxX)
+	c.y = math.Max(math.Min(c.y, -minY), screenHeight-maxY)
+}
+
+func (c *Camera) round() {
+	c.X = math.Round(c.x)
+	c.Y = math.Round(c.y)
+}
+
+// drag moves focus just far enough that target is within reach of it
+func drag(focus, target, reach float64) float64 {
+	if target > focus+reach {
+		return target - reach
+	}
+	if target < focus-reach {
+		return target + reach
+	}
+	return focus
 }
 
-func (c *Camera) Constrain(tilemapWidthPixels, tilemapHeightPixels, screenWidth, screenHeight float64) {
-	c.X = math.Min(c.X, 0.0)
-	c.Y = math.Min(c.Y, 0.0)
+func lerp(from, to, t float64) float64 {
+	return from + (to-from)*t
+}
 
-	c.X = math.Max(c.X, screenWidth-tilemapWidthPixels)
-	c.Y = math.Max(c.Y, screenHeight-tilemapHeightPixels)
+func sign(v float64) float64 {
+	if v > 0 {
+		return 1
+	}
+	if v < 0 {
+		return -1
+	}
+	return 0
 }
@@ -0,0 +1,135 @@
+package camera
+
+import "testing"
+
+func TestDrag(t *testing.T) {
+	tests := []struct {
+		name                 string
+		focus, target, reach float64
+		want                 float64
+	}{
+		{"inside", 100, 110, 16, 100},
+		{"on the edge", 100, 116, 16, 100},
+		{"past the right edge", 100, 120, 16, 104},
+		{"past the left edge", 100, 80, 16, 96},
+		{"no dead zone", 100, 80, 0, 80},
+	}
+	for _, test := range tests {
+		if got := drag(test.focus, test.target, test.reach); got != test.want {
+			t.Errorf("%s: drag(%v, %v, %v) = %v, want %v",
+				test.name, test.focus, test.target, test.reach, got, test.want)
+		}
+	}
+}
+
+func TestFollowTargetJumpsFirst(t *testing.T) {
+	cam := NewCamera(0, 0)
+	cam.Smoothing = 0.1
+	cam.FollowTarget(500, 300, 320, 240)
+	if cam.X != -340 || cam.Y != -180 {
+		t.Errorf("first FollowTarget moved the view to %v,%v, want -340,-180", cam.X, cam.Y)
+	}
+
+	// after Reset the next call jumps again instead of easing
+	cam.Reset()
+	cam.FollowTarget(-100, -50, 320, 240)
+	if cam.X != 260 || cam.Y != 170 {
+		t.Errorf("FollowTarget after Reset moved the view to %v,%v, want 260,170", cam.X, cam.Y)
+	}
+}
+
+func TestFollowTarget(t *testing.T) {
+	tests := []struct {
+		name      string
+		smoothing float64
+		deadZone  float64
+		lookAhead float64
+		// target positions after the first, from 160,120
+		moves []float64
+		want  float64
+	}{
+		{name: "snaps", smoothing: 1, moves: []float64{200}, want: -40},
+		{name: "eases", smoothing: 0.5, moves: []float64{200}, want: -20},
+		{name: "eases on", smoothing: 0.5, moves: []float64{200, 200}, want: -30},
+		{name: "inside the dead zone", smoothing: 1, deadZone: 64, moves: []float64{190}, want: 0},
+		{name: "past the dead zone", smoothing: 1, deadZone: 64, moves: []float64{200}, want: -8},
+		{name: "looks ahead", smoothing: 1, lookAhead: 24, moves: []float64{200}, want: -64},
+		{name: "no look ahead standing still", smoothing: 1, lookAhead: 24, moves: []float64{200, 200}, want: -40},
+		{name: "no look ahead inside the dead zone", smoothing: 1, deadZone: 64, lookAhead: 24, moves: []float64{190}, want: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cam := NewCamera(0, 0)
+			cam.Smoothing = test.smoothing
+			cam.DeadZoneWidth = test.deadZone
+			cam.DeadZoneHeight = test.deadZone
+			cam.LookAhead = test.lookAhead
+			cam.FollowTarget(160, 120, 320, 240)
+			for _, x := range test.moves {
+				cam.FollowTarget(x, 120, 320, 240)
+			}
+			if cam.X != test.want {
+				t.Errorf("X = %v, want %v", cam.X, test.want)
+			}
+			if cam.Y != 0 {
+				t.Errorf("Y = %v, want 0 as the target only moved sideways", cam.Y)
+			}
+		})
+	}
+}
+
+func TestFollowTargetRoundsToPixels(t *testing.T) {
+	cam := NewCamera(0, 0)
+	cam.Smoothing = 0.3
+	cam.FollowTarget(160, 120, 320, 240)
+	for step := 0; step < 10; step++ {
+		cam.FollowTarget(171, 120, 320, 240)
+		if cam.X != float64(int(cam.X)) {
+			t.Fatalf("step %d: X = %v, want a whole pixel", step, cam.X)
+		}
+	}
+	// the unrounded position keeps easing, so the view gets there
+	if cam.X != -11 {
+		t.Errorf("X = %v after easing, want -11", cam.X)
+	}
+}
+
+func TestConstrain(t *testing.T) {
+	tests := []struct {
+		name                   string
+		x, y                   float64
+		minX, minY, maxX, maxY float64
+		wantX, wantY           float64
+	}{
+		{"inside", -100, -50, 0, 0, 1000, 1000, -100, -50},
+		{"past the top left", 20, 30, 0, 0, 1000, 1000, 0, 0},
+		{"past the bottom right", -900, -900, 0, 0, 1000, 1000, -680, -760},
+		{"negative area", 0, 0, -500, -400, 500, 400, 0, 0},
+		{"past a negative top left", 600, 500, -500, -400, 500, 400, 500, 400},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cam := NewCamera(test.x, test.y)
+			cam.Constrain(test.minX, test.minY, test.maxX, test.maxY, 320, 240)
+			if cam.X != test.wantX || cam.Y != test.wantY {
+				t.Errorf("view at %v,%v, want %v,%v", cam.X, cam.Y, test.wantX, test.wantY)
+			}
+		})
+	}
+}
+
+func TestConstrainEasesFromTheEdge(t *testing.T) {
+	cam := NewCamera(0, 0)
+	cam.Smoothing = 0.5
+	cam.FollowTarget(160, 120, 320, 240)
+	// the target walks far past the left edge and comes back
+	for step := 0; step < 20; step++ {
+		cam.FollowTarget(-400, 120, 320, 240)
+		cam.Constrain(0, 0, 1000, 1000, 320, 240)
+	}
+	cam.FollowTarget(200, 120, 320, 240)
+	cam.Constrain(0, 0, 1000, 1000, 320, 240)
+	if cam.X != -20 {
+		t.Errorf("X = %v, want the view to ease from the edge to -20", cam.X)
+	}
+}
@@ -0,0 +1,321 @@
+// Command mapcheck checks Tiled maps for mistakes the game would trip over,
+// loading them the way the game does. It prints a line for every problem
+// found and exits with status 1 if there were any, so it can run before
+// commits.
+//
+// Usage:
+//
+//	mapcheck [-dir assets] map...
+//
+// Map paths are relative to dir, which tileset, image and target map paths
+// in the maps may not leave.
+//
+// Tiles may be flipped and rotated freely unless their tileset sets its
+// allowed transformations in tiled, then only the flips and rotations it
+// allows may be used. Turning a tile upside down counts as a rotation, or
+// as flipping it both ways. The hexagonal rotation bit is always a problem,
+// the game only draws orthogonal maps.
+package main
+
+import (
+	"EndlessJourney/tilemap"
+	"EndlessJourney/tileset"
+	"flag"
+	"fmt"
+	"image"
+	_ "image/png"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// objectClasses are the object classes the game knows, with the
+// properties objects of each class must have
+var objectClasses = map[string][]string{
+	"player":   nil,
+	"enemy":    nil,
+	"potion":   nil,
+	"collider": nil,
+	"trigger":  nil,
+	"spawn":    nil,
+	"portal":   {"map"},
+	"landmark": {"map"},
+}
+
+func main() {
+	dir := flag.String("dir", ".", "directory the map paths are relative to")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: mapcheck [-dir assets] map...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	fsys := os.DirFS(*dir)
+	failed := false
+	for _, arg := range flag.Args() {
+		name := filepath.ToSlash(filepath.Clean(arg))
+		for _, problem := range checkMap(fsys, name) {
+			fmt.Printf("%s: %s\n", name, problem)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// checkMap returns the problems found in the map at name in fsys
+func checkMap(fsys fs.FS, name string) []string {
+	if !fs.ValidPath(name) {
+		return []string{"path is outside of the map directory"}
+	}
+
+	cfs := &checkFS{FS: fsys}
+	tm, err := tilemap.NewTilemap(cfs, name)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	problems := make([]string, 0)
+	tilesets, err := tm.GenTilesets()
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	err = tm.LoadImages()
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	problems = append(problems, checkLayers(tm, tilesets)...)
+	problems = append(problems, checkObjects(fsys, tm, path.Dir(name))...)
+	return append(cfs.problems, problems...)
+}
+
+// checkLayers checks that tile layers match the map's size and only hold
+// tiles of the map's tilesets, without bits the game doesn't use. Tiles
+// aren't checked against the tilesets if they failed to load.
+func checkLayers(tm *tilemap.TilemapJSON, tilesets tilemap.Tilesets) []string {
+	problems := make([]string, 0)
+	for _, layer := range tm.Layers {
+		if layer.Type != tilemap.TileLayer {
+			continue
+		}
+
+		if !tm.Infinite && (layer.Width != tm.Width || layer.Height != tm.Height) {
+			problems = append(problems, fmt.Sprintf(
+				"layer %q is %dx%d, the map is %dx%d",
+				layer.Name, layer.Width, layer.Height, tm.Width, tm.Height,
+			))
+		}
+		for _, chunk := range layer.Chunks {
+			if len(chunk.Data) != chunk.Width*chunk.Height {
+				problems = append(problems, fmt.Sprintf(
+					"layer %q has %d tiles at %d,%d where %dx%d needs %d",
+					layer.Name, len(chunk.Data), chunk.X, chunk.Y,
+					chunk.Width, chunk.Height, chunk.Width*chunk.Height,
+				))
+			}
+		}
+
+		//report each bad gid and flip once rather than every tile using it
+		unknown := make(map[int]badTile)
+		flipped := make(map[placedTile]image.Point)
+		hexagonal := 0
+		layer.ForEachTile(func(x, y, gid int, flip tilemap.Flip) {
+			if flip&tilemap.FlipHexagonal120 != 0 {
+				hexagonal++
+			}
+			if tilesets == nil {
+				return
+			}
+			if _, seen := unknown[gid]; seen {
+				return
+			}
+			//the same lookup the game validates maps with, which also
+			//catches ids missing from image collections
+			_, _, err := tilesets.Tile(gid)
+			if err != nil {
+				unknown[gid] = badTile{image.Pt(x, y), err}
+				return
+			}
+			ts, _, _ := tilesets.Resolve(gid)
+			tile := placedTile{gid, flip &^ tilemap.FlipHexagonal120}
+			if _, seen := flipped[tile]; !seen && !allowsFlip(ts.Transformations(), tile.flip) {
+				flipped[tile] = image.Pt(x, y)
+			}
+		})
+
+		gids := make([]int, 0, len(unknown))
+		for gid := range unknown {
+			gids = append(gids, gid)
+		}
+		sort.Ints(gids)
+		for _, gid := range gids {
+			problems = append(problems, fmt.Sprintf(
+				"layer %q at %d,%d: %v",
+				layer.Name, unknown[gid].position.X, unknown[gid].position.Y, unknown[gid].err,
+			))
+		}
+		tiles := make([]placedTile, 0, len(flipped))
+		for tile := range flipped {
+			tiles = append(tiles, tile)
+		}
+		sort.Slice(tiles, func(i, j int) bool {
+			if tiles[i].gid != tiles[j].gid {
+				return tiles[i].gid < tiles[j].gid
+			}
+			return tiles[i].flip < tiles[j].flip
+		})
+		for _, tile := range tiles {
+			problems = append(problems, fmt.Sprintf(
+				"layer %q at %d,%d: gid %d is %s, which its tileset doesn't allow",
+				layer.Name, flipped[tile].X, flipped[tile].Y, tile.gid, describeFlip(tile.flip),
+			))
+		}
+		if hexagonal > 0 {
+			problems = append(problems, fmt.Sprintf(
+				"layer %q has %d tiles with the hexagonal rotation bit set, which only hexagonal maps use",
+				layer.Name, hexagonal,
+			))
+		}
+	}
+	return problems
+}
+
+// badTile is where a gid the tilesets have no tile for is first used
+type badTile struct {
+	position image.Point
+	err      error
+}
+
+// placedTile is a gid placed with a flip
+type placedTile struct {
+	gid  int
+	flip tilemap.Flip
+}
+
+// allowsFlip reports whether a tileset's transformations allow a flip.
+// Tiled rotates tiles by flipping them diagonally and then horizontally or
+// vertically.
+func allowsFlip(transformations *tileset.TransformationsJSON, flip tilemap.Flip) bool {
+	if transformations == nil {
+		return true
+	}
+	t := transformations
+	switch flip {
+	case 0:
+		return true
+	case tilemap.FlipHorizontal:
+		return t.HFlip
+	case tilemap.FlipVertical:
+		return t.VFlip
+	case tilemap.FlipHorizontal | tilemap.FlipVertical:
+		return t.Rotate || (t.HFlip && t.VFlip)
+	case tilemap.FlipDiagonal | tilemap.FlipHorizontal, tilemap.FlipDiagonal | tilemap.FlipVertical:
+		return t.Rotate
+	}
+	//a diagonal flip on its own or with both others mirrors a rotated tile
+	return t.Rotate && (t.HFlip || t.VFlip)
+}
+
+func describeFlip(flip tilemap.Flip) string {
+	switch flip {
+	case tilemap.FlipHorizontal:
+		return "flipped horizontally"
+	case tilemap.FlipVertical:
+		return "flipped vertically"
+	case tilemap.FlipHorizontal | tilemap.FlipVertical:
+		return "upside down"
+	case tilemap.FlipDiagonal | tilemap.FlipHorizontal, tilemap.FlipDiagonal | tilemap.FlipVertical:
+		return "rotated"
+	}
+	return "rotated and flipped"
+}
+
+// checkObjects checks that objects are of a class the game knows, have
+// the properties it needs and point at maps that exist
+func checkObjects(fsys fs.FS, tm *tilemap.TilemapJSON, dir string) []string {
+	problems := make([]string, 0)
+	for _, object := range tm.Objects() {
+		//objects without a class are left alone by the game
+		if object.Class == "" {
+			continue
+		}
+		required, ok := objectClasses[object.Class]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("object %d has unknown class %q", object.Id, object.Class))
+			continue
+		}
+
+		for _, property := range required {
+			if !object.Properties.Has(property) {
+				problems = append(problems, fmt.Sprintf(
+					"%s %d has no %s property",
+					object.Class, object.Id, property,
+				))
+			}
+		}
+
+		target := object.Properties.String("map", "")
+		if target == "" {
+			continue
+		}
+		_, err := fs.Stat(fsys, path.Join(dir, target))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s %d: %v", object.Class, object.Id, err))
+		}
+	}
+	return problems
+}
+
+// checkFS hands the tileset and tilemap packages a blank placeholder for
+// every image instead of decoding it, only checking that the image is
+// there and readable. Missing and broken images are noted instead of
+// failing, so that every one of them gets reported. Placeholders have the
+// size of the real image, since tilesets that leave out their columns or
+// tile count work them out from it.
+type checkFS struct {
+	fs.FS
+	problems []string
+	// placeholders are shared between images of the same size
+	placeholders map[image.Point]*ebiten.Image
+}
+
+func (c *checkFS) Image(name string) (*ebiten.Image, error) {
+	file, err := c.Open(name)
+	if err != nil {
+		c.problems = append(c.problems, fmt.Sprintf("missing image: %v", err))
+		return c.placeholder(1, 1), nil
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		c.problems = append(c.problems, fmt.Sprintf("bad image %s: %v", name, err))
+		return c.placeholder(1, 1), nil
+	}
+	return c.placeholder(config.Width, config.Height), nil
+}
+
+func (c *checkFS) placeholder(width, height int) *ebiten.Image {
+	size := image.Pt(max(width, 1), max(height, 1))
+	if c.placeholders == nil {
+		c.placeholders = make(map[image.Point]*ebiten.Image)
+	}
+	img, ok := c.placeholders[size]
+	if !ok {
+		img = ebiten.NewImage(size.X, size.Y)
+		c.placeholders[size] = img
+	}
+	return img
+}
+
+var _ tileset.ImageFS = (*checkFS)(nil)
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"EndlessJourney/tilemap"
+	"EndlessJourney/tileset"
+	"bytes"
+	"image"
+	"image/png"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAllowsFlip(t *testing.T) {
+	const (
+		h = tilemap.FlipHorizontal
+		v = tilemap.FlipVertical
+		d = tilemap.FlipDiagonal
+	)
+	none := &tileset.TransformationsJSON{}
+	hflip := &tileset.TransformationsJSON{HFlip: true}
+	vflip := &tileset.TransformationsJSON{VFlip: true}
+	both := &tileset.TransformationsJSON{HFlip: true, VFlip: true}
+	rotate := &tileset.TransformationsJSON{Rotate: true}
+	all := &tileset.TransformationsJSON{HFlip: true, VFlip: true, Rotate: true}
+
+	tests := []struct {
+		name            string
+		transformations *tileset.TransformationsJSON
+		flip            tilemap.Flip
+		want            bool
+	}{
+		{"unset allows anything", nil, d | h | v, true},
+		{"plain tile", none, 0, true},
+		{"horizontal without hflip", none, h, false},
+		{"horizontal", hflip, h, true},
+		{"vertical without vflip", hflip, v, false},
+		{"vertical", vflip, v, true},
+		{"upside down by flipping", both, h | v, true},
+		{"upside down by rotating", rotate, h | v, true},
+		{"upside down with one flip", hflip, h | v, false},
+		{"rotated 90", rotate, d | h, true},
+		{"rotated 270", rotate, d | v, true},
+		{"rotated without rotate", both, d | h, false},
+		{"transposed", all, d, true},
+		{"transposed without a flip", rotate, d, false},
+		{"anti-transposed without rotate", hflip, d | h | v, false},
+		{"anti-transposed", &tileset.TransformationsJSON{VFlip: true, Rotate: true}, d | h | v, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := allowsFlip(test.transformations, test.flip); got != test.want {
+				t.Errorf("allowsFlip(%+v, %s) = %v, want %v",
+					test.transformations, describeFlip(test.flip), got, test.want)
+			}
+		})
+	}
+}
+
+func TestDescribeFlip(t *testing.T) {
+	tests := []struct {
+		flip tilemap.Flip
+		want string
+	}{
+		{tilemap.FlipHorizontal, "flipped horizontally"},
+		{tilemap.FlipVertical, "flipped vertically"},
+		{tilemap.FlipHorizontal | tilemap.FlipVertical, "upside down"},
+		{tilemap.FlipDiagonal | tilemap.FlipHorizontal, "rotated"},
+		{tilemap.FlipDiagonal | tilemap.FlipVertical, "rotated"},
+		{tilemap.FlipDiagonal, "rotated and flipped"},
+	}
+	for _, test := range tests {
+		if got := describeFlip(test.flip); got != test.want {
+			t.Errorf("describeFlip(%b) = %q, want %q", test.flip, got, test.want)
+		}
+	}
+}
+
+// testMaps is a map directory with a good map and one showing every kind
+// of problem mapcheck reports
+func testMaps(t *testing.T) fstest.MapFS {
+	var buf bytes.Buffer
+	// two 16x16 tiles side by side
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 32, 16))); err != nil {
+		t.Fatal(err)
+	}
+
+	return fstest.MapFS{
+		"tilesets/tiles.png": {Data: buf.Bytes()},
+		// no image size or tile count, they come from the image
+		"tilesets/tiles.json": {Data: []byte(`{
+			"image": "tiles.png", "tilewidth": 16, "tileheight": 16,
+			"transformations": {"hflip": true, "vflip": false, "rotate": false}
+		}`)},
+		"tilesets/broken.json": {Data: []byte(`{
+			"image": "missing.png", "tilewidth": 16, "tileheight": 16
+		}`)},
+		"maps/good.json": {Data: []byte(`{
+			"width": 2, "height": 1, "tilewidth": 16, "tileheight": 16,
+			"tilesets": [{"firstgid": 1, "source": "../tilesets/tiles.json"}],
+			"layers": [
+				{"name": "ground", "type": "tilelayer", "width": 2, "height": 1, "data": [1, 2147483650]},
+				{"name": "objects", "type": "objectgroup", "objects": [
+					{"id": 1, "type": "portal", "properties": [{"name": "map", "type": "string", "value": "bad.json"}]},
+					{"id": 2, "name": "note"}
+				]}
+			]
+		}`)},
+		"maps/bad.json": {Data: []byte(`{
+			"width": 2, "height": 2, "tilewidth": 16, "tileheight": 16,
+			"tilesets": [
+				{"firstgid": 1, "source": "../tilesets/tiles.json"},
+				{"firstgid": 10, "source": "../tilesets/broken.json"}
+			],
+			"layers": [
+				{"name": "ground", "type": "tilelayer", "width": 2, "height": 2,
+					"data": [1, 1073741826, 1073741826, 3]},
+				{"name": "short", "type": "tilelayer", "width": 2, "height": 1,
+					"data": [268435457, 268435457, 10]},
+				{"name": "objects", "type": "objectgroup", "objects": [
+					{"id": 1, "type": "portal"},
+					{"id": 2, "type": "landmark", "properties": [{"name": "map", "type": "string", "value": "gone.json"}]},
+					{"id": 3, "type": "dragon"}
+				]}
+			]
+		}`)},
+	}
+}
+
+func TestCheckMap(t *testing.T) {
+	fsys := testMaps(t)
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"maps/good.json", []string{}},
+		{"maps/bad.json", []string{
+			"missing image: open tilesets/missing.png: file does not exist",
+			`layer "ground" at 1,1: tilemap: no tileset contains gid 3`,
+			`layer "ground" at 1,0: gid 2 is flipped vertically, which its tileset doesn't allow`,
+			`layer "short" is 2x1, the map is 2x2`,
+			`layer "short" has 3 tiles at 0,0 where 2x1 needs 2`,
+			`layer "short" has 2 tiles with the hexagonal rotation bit set, which only hexagonal maps use`,
+			"portal 1 has no map property",
+			"landmark 2: open maps/gone.json: file does not exist",
+			`object 3 has unknown class "dragon"`,
+		}},
+		{"../maps/good.json", []string{"path is outside of the map directory"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			problems := checkMap(fsys, test.name)
+			if !slices.Equal(problems, test.want) {
+				t.Errorf("checkMap found\n%q\nwant\n%q", problems, test.want)
+			}
+		})
+	}
+}
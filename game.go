@@ -1,7 +1,9 @@
 package main
 
 import (
+	"EndlessJourney/assets"
 	"EndlessJourney/scenes"
+	"log"
 
 	"github.com/hajimehoshi/ebiten/v2"
 )
@@ -9,27 +11,45 @@ import (
 type Game struct {
 	sceneMap      map[scenes.SceneId]scenes.Scene
 	activeSceneId scenes.SceneId
+	errorScene    *scenes.ErrorScene
+	loadingScene  *scenes.LoadingScene
 }
 
-func NewGame() *Game {
+// NewGame sets up the scenes. With a watcher, the game reloads assets that
+// change on disk.
+func NewGame(assets *assets.Manager, watcher *assets.Watcher) *Game {
 
+	errorScene := scenes.NewErrorScene()
+	loadingScene := scenes.NewLoadingScene()
 	sceneMap := map[scenes.SceneId]scenes.Scene{
-		scenes.GameSceneId:  scenes.NewGameScene(),
-		scenes.StartSceneId: scenes.NewStartScene(),
-		scenes.PauseSceneId: scenes.NewPauseScene(),
+		scenes.GameSceneId:    scenes.NewGameScene(assets, watcher),
+		scenes.StartSceneId:   scenes.NewStartScene(),
+		scenes.PauseSceneId:   scenes.NewPauseScene(),
+		scenes.ErrorSceneId:   errorScene,
+		scenes.LoadingSceneId: loadingScene,
 	}
 	activeSceneId := scenes.StartSceneId
-	sceneMap[activeSceneId].FirstLoad()
 
-	return &Game{
+	g := &Game{
 		sceneMap,
 		activeSceneId,
+		errorScene,
+		loadingScene,
 	}
+	err := sceneMap[activeSceneId].FirstLoad()
+	if err != nil {
+		g.fail(err)
+	}
+	return g
 
 }
 
 func (g *Game) Update() error {
-	nextSceneId := g.sceneMap[g.activeSceneId].Update()
+	nextSceneId, err := g.sceneMap[g.activeSceneId].Update()
+	if err != nil {
+		g.fail(err)
+		return nil
+	}
 	// switched scenes
 	if nextSceneId == scenes.ExitSceneId {
 		g.sceneMap[g.activeSceneId].OnExit()
@@ -38,8 +58,18 @@ func (g *Game) Update() error {
 	if nextSceneId != g.activeSceneId {
 		nextScene := g.sceneMap[nextSceneId]
 		// if not loaded? then load in
-		if !nextScene.IsLoaded() {
-			nextScene.FirstLoad()
+		if loader, ok := nextScene.(scenes.BackgroundLoader); ok && !nextScene.IsLoaded() {
+			// slow scenes load behind the loading scene, which
+			// switches to them once they are ready
+			g.loadingScene.Load(nextSceneId, loader)
+			nextSceneId = scenes.LoadingSceneId
+			nextScene = g.loadingScene
+		} else if !nextScene.IsLoaded() {
+			err := nextScene.FirstLoad()
+			if err != nil {
+				g.fail(err)
+				return nil
+			}
 		}
 		nextScene.OnEnter()
 		g.sceneMap[g.activeSceneId].OnExit()
@@ -48,6 +78,15 @@ func (g *Game) Update() error {
 	return nil
 }
 
+// fail switches to the error scene showing err
+func (g *Game) fail(err error) {
+	log.Print(err)
+	g.errorScene.SetError(err)
+	g.errorScene.OnEnter()
+	g.sceneMap[g.activeSceneId].OnExit()
+	g.activeSceneId = scenes.ErrorSceneId
+}
+
 func (g *Game) Draw(screen *ebiten.Image) {
 	g.sceneMap[g.activeSceneId].Draw(screen)
 }
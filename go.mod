@@ -2,13 +2,18 @@ module EndlessJourney
 
 go 1.22.2
 
-require github.com/hajimehoshi/ebiten/v2 v2.8.5
+require (
+	github.com/hajimehoshi/ebiten/v2 v2.8.5
+	github.com/klauspost/compress v1.17.11
+)
 
 require (
 	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20200707082815-5321531c36a2 // indirect
+	github.com/go-text/typesetting v0.2.0 // indirect
 	golang.org/x/exp v0.0.0-20190731235908-ec7cb31e5a56 // indirect
 	golang.org/x/image v0.21.0 // indirect
 	golang.org/x/mobile v0.0.0-20210208171126-f462b3930c8f // indirect
+	golang.org/x/text v0.19.0 // indirect
 )
 
 require (
@@ -1,17 +1,48 @@
 package main
 
 import (
+	"EndlessJourney/assets"
+	"flag"
+	"io/fs"
 	"log"
+	"os"
 
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
 func main() {
+	modsDir := flag.String("mods", "", "directory of assets that replace the built in ones")
+	devDir := flag.String("dev", "", "asset directory to load from instead of the built in assets, reloading files that change there or in the mods")
+	flag.Parse()
+
+	var base fs.FS = assets.Embedded()
+	if *devDir != "" {
+		base = os.DirFS(*devDir)
+	}
+
+	assetsFS, err := assets.Load(base, *modsDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	//while developing, edits to the mods are picked up as well
+	var watcher *assets.Watcher
+	if *devDir != "" {
+		watched := []fs.FS{base}
+		if *modsDir != "" {
+			watched = append(watched, os.DirFS(*modsDir))
+		}
+		watcher, err = assets.NewWatcher(watched...)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	ebiten.SetWindowSize(640, 480)
 	ebiten.SetWindowTitle("Endless Journey")
 	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
 
-	game := NewGame()
+	game := NewGame(assets.NewManager(assetsFS), watcher)
 
 	if err := ebiten.RunGame(game); err != nil {
 		log.Fatal(err)
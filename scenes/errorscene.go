@@ -0,0 +1,74 @@
+package scenes
+
+import (
+	"errors"
+	"image/color"
+	"io/fs"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// ErrorScene shows what went wrong when a scene failed, naming the asset
+// that failed to load if there is one
+type ErrorScene struct {
+	loaded bool
+	err    error
+}
+
+func NewErrorScene() *ErrorScene {
+	return &ErrorScene{
+		loaded: false,
+		err:    nil,
+	}
+}
+
+// SetError sets the error the scene shows
+func (s *ErrorScene) SetError(err error) {
+	s.err = err
+}
+
+func (s *ErrorScene) Draw(screen *ebiten.Image) {
+	screen.Fill(color.RGBA{64, 0, 0, 255})
+
+	message := "Something went wrong.\n\n"
+	var pathErr *fs.PathError
+	if errors.As(s.err, &pathErr) {
+		//the path error's message repeats the path, show just its cause
+		message = "Failed to load " + pathErr.Path + "\n\n" + pathErr.Err.Error() + "\n\n"
+	} else if s.err != nil {
+		message += s.err.Error() + "\n\n"
+	}
+	message += "Press enter to go back to the start or Q to quit."
+	ebitenutil.DebugPrint(screen, wrapText(message, 320/6))
+}
+
+func (s *ErrorScene) FirstLoad() error {
+	s.loaded = true
+	return nil
+}
+
+func (s *ErrorScene) IsLoaded() bool {
+	return s.loaded
+}
+
+func (s *ErrorScene) OnEnter() {
+
+}
+
+func (s *ErrorScene) OnExit() {
+
+}
+
+func (s *ErrorScene) Update() (SceneId, error) {
+	if inpututil.IsKeyJustPressed(ebiten.KeyQ) {
+		return ExitSceneId, nil
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		return StartSceneId, nil
+	}
+	return ErrorSceneId, nil
+}
+
+var _ Scene = (*ErrorScene)(nil)
@@ -2,18 +2,20 @@ package scenes
 
 import (
 	"EndlessJourney/animations"
+	"EndlessJourney/assets"
 	"EndlessJourney/camera"
 	"EndlessJourney/components"
 	"EndlessJourney/constants"
 	"EndlessJourney/entities"
 	"EndlessJourney/spritesheet"
+	"EndlessJourney/tiled"
 	"EndlessJourney/tilemap"
-	"EndlessJourney/tileset"
+	"EndlessJourney/worldgen"
 	"fmt"
 	"image"
 	"image/color"
-	"log"
 	"math"
+	"sort"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
@@ -21,30 +23,83 @@ import (
 	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
+// name of the tile layer whose tiles are depth sorted with the entities
+const objectsLayerName = "objects"
+
+// name of the tile layer generated terrain goes into
+const groundLayerName = "ground"
+
+const (
+	playerImgPath   = "images/ninja.png"
+	skeletonImgPath = "images/skeleton.png"
+	potionImgPath   = "images/potion.png"
+	tilemapImgPath  = "images/TilesetFloor.png"
+)
+
+// map the game starts in
+const startMapPath = "maps/world.json"
+
 type GameScene struct {
 	loaded            bool
+	progress          Progress
+	assets            *assets.Manager
+	watcher           *assets.Watcher
+	reloadTicks       int
+	reloadErr         error
 	player            *entities.Player
 	playerSpriteSheet *spritesheet.SpriteSheet
 	enemies           []*entities.Enemy
 	potions           []*entities.Potion
+	skeletonImg       *ebiten.Image
+	potionImg         *ebiten.Image
+	mapPath           string
+	mapStates         map[string]*mapState
 	tilemapJSON       *tilemap.TilemapJSON
-	tilesets          []tileset.Tileset
+	objects           []tiled.Object
+	tilesets          tilemap.Tilesets
+	renderer          *tilemap.Renderer
+	objectsLayer      int
 	tilemapImg        *ebiten.Image
 	cam               *camera.Camera
 	colliders         []image.Rectangle
+	triggers          []*trigger
+	world             *worldgen.World
+	chunkStates       map[image.Point]*mapState
+}
+
+// trigger is an area of the map that reacts when the player enters it
+type trigger struct {
+	tiled.Object
+	entered bool
 }
 
-func NewGameScene() *GameScene {
+// NewGameScene makes the game scene. With a watcher, assets that change
+// on disk are reloaded into the running game.
+func NewGameScene(assets *assets.Manager, watcher *assets.Watcher) *GameScene {
 	return &GameScene{
+		assets:            assets,
+		watcher:           watcher,
+		reloadTicks:       0,
+		reloadErr:         nil,
 		player:            nil,
 		playerSpriteSheet: nil,
 		enemies:           make([]*entities.Enemy, 0),
 		potions:           make([]*entities.Potion, 0),
+		skeletonImg:       nil,
+		potionImg:         nil,
+		mapPath:           "",
+		mapStates:         make(map[string]*mapState),
 		tilemapJSON:       nil,
+		objects:           make([]tiled.Object, 0),
 		tilesets:          nil,
+		renderer:          nil,
+		objectsLayer:      -1,
 		tilemapImg:        nil,
 		cam:               nil,
 		colliders:         make([]image.Rectangle, 0),
+		triggers:          make([]*trigger, 0),
+		world:             nil,
+		chunkStates:       make(map[image.Point]*mapState),
 		loaded:            false,
 	}
 }
@@ -53,42 +108,102 @@ func (g *GameScene) IsLoaded() bool {
 	return g.loaded
 }
 
+func (g *GameScene) Progress() *Progress {
+	return &g.progress
+}
+
 func (g *GameScene) Draw(screen *ebiten.Image) {
 
 	screen.Fill(color.RGBA{120, 180, 255, 255})
 
-	opts := ebiten.DrawImageOptions{}
-
-	//loop over the layers
-	for layerIndex, layer := range g.tilemapJSON.Layers {
-		//loop over tiles in the layer data
-		for index, id := range layer.Data {
+	//the objects layer is drawn together with the entities, layers above
+	//it still cover everything
+	for index := range g.tilemapJSON.Layers {
+		if index == g.objectsLayer {
+			g.drawDepthSorted(screen)
+			continue
+		}
+		g.renderer.DrawLayer(screen, index, g.cam, 320, 240)
+	}
+	if g.objectsLayer < 0 {
+		g.drawDepthSorted(screen)
+	}
 
-			if id == 0 {
-				continue
-			}
-			//get the tile position of the tile
-			x := index % layer.Width
-			y := index / layer.Width
+	for _, collider := range g.colliders {
+		vector.StrokeRect(
+			screen,
+			float32(collider.Min.X)+float32(g.cam.X),
+			float32(collider.Min.Y)+float32(g.cam.Y),
+			float32(collider.Dx()),
+			float32(collider.Dy()),
+			1.0,
+			color.RGBA{255, 0, 0, 255},
+			true,
+		)
+	}
 
-			//convert tile position into pixel position
-			x *= constants.Tilesize
-			y *= constants.Tilesize
+	if g.reloadErr != nil {
+		ebitenutil.DebugPrint(screen, wrapText("reload failed: "+g.reloadErr.Error(), 320/6))
+	}
+}
 
-			img := g.tilesets[layerIndex].Img(id)
+// depthItem is anything drawn in the depth sorted pass
+type depthItem struct {
+	baseY float64
+	draw  func(screen *ebiten.Image)
+}
 
-			opts.GeoM.Translate(float64(x), float64(y))
+// drawDepthSorted draws the entities and the tiles of the objects layer
+// from back to front, going by the y position of their base, so that the
+// player can walk behind buildings
+func (g *GameScene) drawDepthSorted(screen *ebiten.Image) {
+	items := make([]depthItem, 0)
+
+	if g.objectsLayer >= 0 && g.tilemapJSON.Layers[g.objectsLayer].Visible {
+		_, tileHeight := g.tilemapJSON.TileSize()
+		g.renderer.ForEachVisibleTile(g.objectsLayer, g.cam, 320, 240, func(x, y, gid int, flip tilemap.Flip) {
+			items = append(items, depthItem{
+				baseY: float64((y + 1) * tileHeight),
+				draw: func(screen *ebiten.Image) {
+					g.renderer.DrawTile(screen, g.objectsLayer, x, y, gid, flip, g.cam)
+				},
+			})
+		})
+	}
 
-			opts.GeoM.Translate(0.0, -float64(img.Bounds().Dy())+constants.Tilesize)
+	items = append(items, depthItem{
+		baseY: g.player.Y + constants.Tilesize,
+		draw:  g.drawPlayer,
+	})
+	for _, sprite := range g.enemies {
+		items = append(items, depthItem{
+			baseY: sprite.Y + constants.Tilesize,
+			draw: func(screen *ebiten.Image) {
+				g.drawSprite(screen, sprite.Sprite)
+			},
+		})
+	}
+	for _, sprite := range g.potions {
+		items = append(items, depthItem{
+			baseY: sprite.Y + constants.Tilesize,
+			draw: func(screen *ebiten.Image) {
+				g.drawSprite(screen, sprite.Sprite)
+			},
+		})
+	}
 
-			opts.GeoM.Translate(g.cam.X, g.cam.Y)
+	//stable so that tiles on the same row keep their map order
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].baseY < items[j].baseY
+	})
+	for _, item := range items {
+		item.draw(screen)
+	}
+}
 
-			screen.DrawImage(img, &opts)
+func (g *GameScene) drawPlayer(screen *ebiten.Image) {
+	opts := ebiten.DrawImageOptions{}
 
-			//reset the opts for the next tile
-			opts.GeoM.Reset()
-		}
-	}
 	//set the translation of our drawImageOptions to the player's position
 	opts.GeoM.Translate(g.player.X, g.player.Y)
 	opts.GeoM.Translate(g.cam.X, g.cam.Y)
@@ -106,81 +221,67 @@ func (g *GameScene) Draw(screen *ebiten.Image) {
 		).(*ebiten.Image),
 		&opts,
 	)
+}
 
-	opts.GeoM.Reset()
-
-	for _, sprite := range g.enemies {
-		opts.GeoM.Translate(sprite.X, sprite.Y)
-		opts.GeoM.Translate(g.cam.X, g.cam.Y)
-
-		screen.DrawImage(
-			sprite.Img.SubImage(
-				image.Rect(0, 0, constants.Tilesize, constants.Tilesize),
-			).(*ebiten.Image),
-			&opts,
-		)
-
-		opts.GeoM.Reset()
-	}
-	for _, sprite := range g.potions {
-		opts.GeoM.Translate(sprite.X, sprite.Y)
-		opts.GeoM.Translate(g.cam.X, g.cam.Y)
-
-		screen.DrawImage(
-			sprite.Img.SubImage(
-				image.Rect(0, 0, constants.Tilesize, constants.Tilesize),
-			).(*ebiten.Image),
-			&opts,
-		)
-
-		opts.GeoM.Reset()
-	}
+func (g *GameScene) drawSprite(screen *ebiten.Image, sprite *entities.Sprite) {
+	opts := ebiten.DrawImageOptions{}
+	opts.GeoM.Translate(sprite.X, sprite.Y)
+	opts.GeoM.Translate(g.cam.X, g.cam.Y)
 
-	for _, collider := range g.colliders {
-		vector.StrokeRect(
-			screen,
-			float32(collider.Min.X)+float32(g.cam.X),
-			float32(collider.Min.Y)+float32(g.cam.Y),
-			float32(collider.Dx()),
-			float32(collider.Dy()),
-			1.0,
-			color.RGBA{255, 0, 0, 255},
-			true,
-		)
-	}
+	screen.DrawImage(
+		sprite.Img.SubImage(
+			image.Rect(0, 0, constants.Tilesize, constants.Tilesize),
+		).(*ebiten.Image),
+		&opts,
+	)
 }
 
-func (g *GameScene) FirstLoad() {
-
-	playerImg, _, err := ebitenutil.NewImageFromFile("assets/images/ninja.png")
-	if err != nil {
-		//handle error
-		log.Fatal(err)
+// FirstLoad loads the sprites and the starting map. The assets decode in
+// parallel first, the scene is then built from the cached assets. A failed
+// load leaves the scene as it was, ready to be loaded again.
+func (g *GameScene) FirstLoad() error {
+	g.progress.Reset()
+	imgPaths := []string{playerImgPath, skeletonImgPath, potionImgPath, tilemapImgPath}
+
+	preload := newPreloader(g.assets, &g.progress)
+	defer preload.release()
+	for _, name := range imgPaths {
+		preload.image(name)
 	}
-	skeletonImg, _, err := ebitenutil.NewImageFromFile("assets/images/skeleton.png")
+	preload.gameMap(startMapPath)
+	err := preload.wait()
 	if err != nil {
-		//handle error
-		log.Fatal(err)
+		return err
 	}
-	potionImg, _, err := ebitenutil.NewImageFromFile("assets/images/potion.png")
-	if err != nil {
-		//handle error
-		log.Fatal(err)
+	g.progress.Add(1)
+	defer g.progress.Done()
+
+	imgs := make([]*ebiten.Image, 0, len(imgPaths))
+	releaseImgs := func() {
+		for _, name := range imgPaths[:len(imgs)] {
+			g.assets.ReleaseImage(name)
+		}
 	}
-	tilemapImg, _, err := ebitenutil.NewImageFromFile("assets/images/TilesetFloor.png")
-	if err != nil {
-		//handle error
-		log.Fatal(err)
+	for _, name := range imgPaths {
+		img, err := g.assets.Image(name)
+		if err != nil {
+			releaseImgs()
+			return err
+		}
+		imgs = append(imgs, img)
 	}
+	playerImg, skeletonImg, potionImg, tilemapImg := imgs[0], imgs[1], imgs[2], imgs[3]
 
-	tilemapJSON, err := tilemap.NewTilemapJSON("assets/maps/spawn.json")
+	opened, err := g.openMap(startMapPath)
 	if err != nil {
-		log.Fatal(err)
+		releaseImgs()
+		return err
 	}
-
-	tilesets, err := tilemapJSON.GenTilesets()
+	spawn, err := findSpawn(opened.objects, opened.path, "")
 	if err != nil {
-		log.Fatal(err)
+		g.closeMap(opened)
+		releaseImgs()
+		return err
 	}
 
 	playerSpriteSheet := spritesheet.NewSpriteSheet(4, 7, constants.Tilesize)
@@ -188,8 +289,6 @@ func (g *GameScene) FirstLoad() {
 	g.player = &entities.Player{
 		Sprite: &entities.Sprite{
 			Img: playerImg,
-			X:   50.0,
-			Y:   50.0,
 		},
 		Health: 3,
 		Animations: map[entities.PlayerState]*animations.Animation{
@@ -202,56 +301,100 @@ func (g *GameScene) FirstLoad() {
 	}
 
 	g.playerSpriteSheet = playerSpriteSheet
+	g.skeletonImg = skeletonImg
+	g.potionImg = potionImg
+	g.tilemapImg = tilemapImg
+	g.cam = camera.NewCamera(0.0, 0.0)
+	g.cam.Smoothing = 0.12
+	g.cam.DeadZoneWidth = 32
+	g.cam.DeadZoneHeight = 24
+	g.cam.LookAhead = 24
 
-	g.enemies = []*entities.Enemy{
-		{
-			Sprite: &entities.Sprite{
-				Img: skeletonImg,
-				X:   100.0,
-				Y:   100.0,
-			},
-			FollowsPlayer: true,
-			CombatComp:    components.NewEnemyCombat(3, 1, 30),
-		},
-		{
+	g.enterMap(opened)
+	g.movePlayer(spawn)
+	g.loaded = true
+	return nil
+}
+
+// spawnObjects creates the triggers and portals described by the map's
+// objects, and the enemies and potions too if withEntities is set
+func (g *GameScene) spawnObjects(objects []tiled.Object, withEntities bool) {
+	g.enemies = make([]*entities.Enemy, 0)
+	g.potions = make([]*entities.Potion, 0)
+	g.triggers = make([]*trigger, 0)
+
+	for _, object := range objects {
+		switch object.Class {
+		case "enemy", "potion":
+			if withEntities {
+				g.spawnEntity(object)
+			}
+		case "trigger", "portal":
+			g.triggers = append(g.triggers, &trigger{Object: object})
+		}
+	}
+}
+
+// spawnEntity creates the enemy or potion an object describes
+func (g *GameScene) spawnEntity(object tiled.Object) {
+	switch object.Class {
+	case "enemy":
+		g.enemies = append(g.enemies, &entities.Enemy{
 			Sprite: &entities.Sprite{
-				Img: skeletonImg,
-				X:   150.0,
-				Y:   150.0,
+				Img: g.skeletonImg,
+				X:   object.X,
+				Y:   object.Y,
 			},
-			FollowsPlayer: true,
-			CombatComp:    components.NewEnemyCombat(3, 1, 30),
-		},
-		{
+			FollowsPlayer: object.Properties.Bool("follows", true),
+			CombatComp: components.NewEnemyCombat(
+				object.Properties.Int("health", 3),
+				object.Properties.Int("attack", 1),
+				object.Properties.Int("cooldown", 30),
+			),
+		})
+	case "potion":
+		g.potions = append(g.potions, &entities.Potion{
 			Sprite: &entities.Sprite{
-				Img: skeletonImg,
-				X:   200.0,
-				Y:   200.0,
+				Img: g.potionImg,
+				X:   object.X,
+				Y:   object.Y,
 			},
-			FollowsPlayer: true,
-			CombatComp:    components.NewEnemyCombat(3, 1, 30),
-		},
+			AmtHeal: uint(object.Properties.Int("heal", 1)),
+		})
 	}
+}
 
-	g.tilemapJSON = tilemapJSON
-	g.tilesets = tilesets
-	g.tilemapImg = tilemapImg
-	g.cam = camera.NewCamera(0.0, 0.0)
-	g.colliders = []image.Rectangle{
-		image.Rect(100, 100, 116, 116),
+// updateColliders collects the colliders of the map's objects and of its
+// solid tiles
+func (g *GameScene) updateColliders() {
+	g.colliders = make([]image.Rectangle, 0)
+	for _, object := range g.objects {
+		if object.Class == "collider" {
+			g.colliders = append(g.colliders, object.Bounds())
+		}
 	}
+	g.colliders = append(g.colliders, g.tilemapJSON.Colliders(g.tilesets)...)
+}
 
-	g.potions = []*entities.Potion{
-		{
-			Sprite: &entities.Sprite{
-				Img: potionImg,
-				X:   210.0,
-				Y:   100.0,
-			},
-			AmtHeal: 1.0,
-		},
+// updateTriggers fires each trigger the player has just walked into and
+// returns the portal they walked into, if any
+func (g *GameScene) updateTriggers() *trigger {
+	var portal *trigger
+	centerX := g.player.X + constants.Tilesize/2
+	centerY := g.player.Y + constants.Tilesize/2
+	for _, trigger := range g.triggers {
+		inside := trigger.Contains(centerX, centerY)
+		if inside && !trigger.entered {
+			if message := trigger.Properties.String("message", ""); message != "" {
+				fmt.Println(message)
+			}
+			if trigger.Class == "portal" {
+				portal = trigger
+			}
+		}
+		trigger.entered = inside
 	}
-	g.loaded = true
+	return portal
 }
 
 func (g *GameScene) OnEnter() {
@@ -262,12 +405,16 @@ func (g *GameScene) OnExit() {
 
 }
 
-func (g *GameScene) Update() SceneId {
+func (g *GameScene) Update() (SceneId, error) {
 	if inpututil.IsKeyJustPressed(ebiten.KeyQ) {
-		return ExitSceneId
+		return ExitSceneId, nil
 	}
 	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
-		return PauseSceneId
+		return PauseSceneId, nil
+	}
+
+	if g.watcher != nil {
+		g.hotReload()
 	}
 
 	g.player.Dx = 0.0
@@ -294,6 +441,10 @@ func (g *GameScene) Update() SceneId {
 
 	CheckCollisionVertical(g.player.Sprite, g.colliders)
 
+	portal := g.updateTriggers()
+
+	g.tilesets.Update()
+
 	activeAnim := g.player.ActiveAnimation(int(g.player.Dx), int(g.player.Dy))
 	if activeAnim != nil {
 		activeAnim.Update()
@@ -402,18 +553,38 @@ func (g *GameScene) Update() SceneId {
 		}
 	}
 
+	//switch maps once the frame is done with the current one
+	if portal != nil {
+		err := g.usePortal(portal.Object)
+		if err != nil && g.watcher != nil {
+			//while developing, a broken map shouldn't end the game
+			g.reloadErr = err
+		} else if err != nil {
+			return GameSceneId, err
+		}
+	}
+
 	g.cam.FollowTarget(g.player.X+8, g.player.Y+8, 320, 240)
-	g.cam.Constrain(
-		float64(g.tilemapJSON.Layers[0].Width)*constants.Tilesize,
-		float64(g.tilemapJSON.Layers[0].Height)*constants.Tilesize,
-		320,
-		240,
-	)
 
-	return GameSceneId
+	//the generated world has no edges to keep the camera in
+	if g.world != nil {
+		g.streamWorld()
+	} else {
+		bounds := g.tilemapJSON.PixelBounds()
+		g.cam.Constrain(
+			float64(bounds.Min.X),
+			float64(bounds.Min.Y),
+			float64(bounds.Max.X),
+			float64(bounds.Max.Y),
+			320,
+			240,
+		)
+	}
+
+	return GameSceneId, nil
 }
 
-var _ Scene = (*GameScene)(nil)
+var _ BackgroundLoader = (*GameScene)(nil)
 
 func CheckCollisionHorizontal(sprite *entities.Sprite, colliders []image.Rectangle) {
 	for _, collider := range colliders {
@@ -447,7 +618,7 @@ func CheckCollisionVertical(sprite *entities.Sprite, colliders []image.Rectangle
 			if sprite.Dy > 0.0 {
 				sprite.Y = float64(collider.Min.Y) - constants.Tilesize
 			} else if sprite.Dy < 0.0 {
-				sprite.Y = float64(collider.Max.X)
+				sprite.Y = float64(collider.Max.Y)
 			}
 		}
 	}
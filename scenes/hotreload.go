@@ -0,0 +1,147 @@
+package scenes
+
+import (
+	"path"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// ticks between looks for assets that changed on disk
+const reloadInterval = 30
+
+// hotReload reloads the assets that changed on disk and swaps them into
+// the running scene, leaving the player where they are and the entities
+// as they are. A file that fails to load is reported on screen and the
+// scene carries on with what it had, which is kept until a reload works.
+func (g *GameScene) hotReload() {
+	g.reloadTicks++
+	if g.reloadTicks < reloadInterval {
+		return
+	}
+	g.reloadTicks = 0
+
+	changed, err := g.watcher.Changed()
+	if err != nil {
+		g.reloadErr = err
+		return
+	}
+	if len(changed) == 0 {
+		return
+	}
+
+	//changed files that nothing has loaded count too, landmark maps are
+	//only loaded while their world is built
+	reloaded := make(map[string]bool)
+	for _, name := range changed {
+		reloaded[path.Clean(name)] = true
+	}
+	for _, name := range changed {
+		names, err := g.assets.Reload(name)
+		for _, name := range names {
+			reloaded[name] = true
+		}
+		if err != nil {
+			g.reloadErr = err
+			return
+		}
+	}
+	g.refreshSprites()
+
+	//reloading the current map keeps its state like coming back to it does
+	if g.mapDependsOn(reloaded) {
+		err = g.loadMap(g.mapPath)
+		if err != nil {
+			g.reloadErr = err
+			return
+		}
+		g.settleTriggers()
+	}
+	g.reloadErr = nil
+
+	//nothing draws what the reload replaced anymore
+	g.assets.ReleaseReplaced()
+}
+
+// mapDependsOn reports whether the current map or the maps of its
+// landmarks are among names
+func (g *GameScene) mapDependsOn(names map[string]bool) bool {
+	if names[g.mapPath] {
+		return true
+	}
+	for _, object := range g.tilemapJSON.Objects() {
+		target := object.Properties.String("map", "")
+		if object.Class == "landmark" && names[path.Join(path.Dir(g.mapPath), target)] {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshSprites points every sprite at the current image for it, which
+// is a new one if a reload changed the image's size
+func (g *GameScene) refreshSprites() {
+	g.player.Img = g.currentImage(playerImgPath, g.player.Img)
+	g.skeletonImg = g.currentImage(skeletonImgPath, g.skeletonImg)
+	g.potionImg = g.currentImage(potionImgPath, g.potionImg)
+
+	states := []*mapState{{enemies: g.enemies, potions: g.potions}}
+	for _, state := range g.chunkStates {
+		states = append(states, state)
+	}
+	for _, state := range g.mapStates {
+		states = append(states, state)
+		for _, chunkState := range state.chunks {
+			states = append(states, chunkState)
+		}
+	}
+
+	for _, state := range states {
+		for _, enemy := range state.enemies {
+			enemy.Img = g.skeletonImg
+		}
+		for _, potion := range state.potions {
+			potion.Img = g.potionImg
+		}
+	}
+}
+
+// currentImage returns the manager's image for name without keeping an
+// extra reference, or old if it can't be loaded
+func (g *GameScene) currentImage(name string, old *ebiten.Image) *ebiten.Image {
+	img, err := g.assets.Image(name)
+	if err != nil {
+		return old
+	}
+	g.assets.ReleaseImage(name)
+	return img
+}
+
+// wrapText breaks text into lines of at most width characters, at spaces
+// where it can, for the debug font which doesn't wrap by itself
+func wrapText(text string, width int) string {
+	lines := make([]string, 0)
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for len(word) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				lines = append(lines, word[:width])
+				word = word[width:]
+			}
+			if line != "" && len(line)+1+len(word) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
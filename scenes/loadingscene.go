@@ -0,0 +1,122 @@
+package scenes
+
+import (
+	"image/color"
+	"sync/atomic"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// BackgroundLoader is a scene slow enough to load that it loads on another
+// goroutine while the loading scene shows its progress. Its FirstLoad must
+// only touch the scene itself and what is safe to share, like the assets.
+type BackgroundLoader interface {
+	Scene
+	Progress() *Progress
+}
+
+// Progress counts the steps of loading a scene as they finish. It is safe
+// to use from the goroutines doing the loading.
+type Progress struct {
+	done  atomic.Int32
+	total atomic.Int32
+}
+
+// Reset forgets every step, for loading again after a failure
+func (p *Progress) Reset() {
+	p.done.Store(0)
+	p.total.Store(0)
+}
+
+// Add adds n steps still to be done
+func (p *Progress) Add(n int) {
+	p.total.Add(int32(n))
+}
+
+// Done marks a step as done
+func (p *Progress) Done() {
+	p.done.Add(1)
+}
+
+// Fraction returns how much of the loading is done, from 0 to 1
+func (p *Progress) Fraction() float64 {
+	total := p.total.Load()
+	if total == 0 {
+		return 0
+	}
+	return min(float64(p.done.Load())/float64(total), 1)
+}
+
+// LoadingScene shows a progress bar while a scene loads in the background
+// and switches to it once it is ready
+type LoadingScene struct {
+	loaded bool
+	target SceneId
+	scene  BackgroundLoader
+	done   chan error
+}
+
+func NewLoadingScene() *LoadingScene {
+	return &LoadingScene{
+		loaded: false,
+		target: StartSceneId,
+		scene:  nil,
+		done:   nil,
+	}
+}
+
+// Load starts loading the scene with the given id on another goroutine
+func (s *LoadingScene) Load(id SceneId, scene BackgroundLoader) {
+	s.target = id
+	s.scene = scene
+	s.done = make(chan error, 1)
+	go func() {
+		s.done <- scene.FirstLoad()
+	}()
+}
+
+func (s *LoadingScene) Draw(screen *ebiten.Image) {
+	screen.Fill(color.Black)
+	ebitenutil.DebugPrint(screen, "Loading...")
+
+	if s.scene == nil {
+		return
+	}
+	//the bar fills up as the steps of loading finish
+	vector.StrokeRect(screen, 40, 112, 240, 16, 1, color.White, false)
+	vector.DrawFilledRect(screen, 42, 114, float32(236*s.scene.Progress().Fraction()), 12, color.White, false)
+}
+
+func (s *LoadingScene) FirstLoad() error {
+	s.loaded = true
+	return nil
+}
+
+func (s *LoadingScene) IsLoaded() bool {
+	return s.loaded
+}
+
+func (s *LoadingScene) OnEnter() {
+
+}
+
+func (s *LoadingScene) OnExit() {
+
+}
+
+func (s *LoadingScene) Update() (SceneId, error) {
+	select {
+	case err := <-s.done:
+		s.scene = nil
+		if err != nil {
+			return LoadingSceneId, err
+		}
+		return s.target, nil
+	default:
+		return LoadingSceneId, nil
+	}
+}
+
+var _ Scene = (*LoadingScene)(nil)
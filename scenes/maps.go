@@ -0,0 +1,337 @@
+package scenes
+
+import (
+	"EndlessJourney/assets"
+	"EndlessJourney/constants"
+	"EndlessJourney/entities"
+	"EndlessJourney/tiled"
+	"EndlessJourney/tilemap"
+	"EndlessJourney/worldgen"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"path"
+)
+
+// mapState is what changes about a map while it is played. It is kept
+// when the player leaves so that the map is as they left it on return,
+// dead enemies stay dead and picked up potions stay gone.
+type mapState struct {
+	enemies []*entities.Enemy
+	potions []*entities.Potion
+	// state of the generated chunks of an endless world map
+	chunks map[image.Point]*mapState
+}
+
+// openedMap is a map loaded with everything it needs, ready to be entered
+type openedMap struct {
+	path         string
+	tilemapJSON  *tilemap.TilemapJSON
+	tilesets     tilemap.Tilesets
+	world        *worldgen.World
+	objectsLayer int
+	objects      []tiled.Object
+}
+
+// loadMap unloads the current map, keeping its state, and loads the map
+// at mapPath in the assets together with its tilesets, colliders and
+// entities. The current map stays untouched if the new one fails to load.
+func (g *GameScene) loadMap(mapPath string) error {
+	opened, err := g.openMap(mapPath)
+	if err != nil {
+		return err
+	}
+	g.enterMap(opened)
+	return nil
+}
+
+// openMap loads the map at mapPath in the assets without touching the
+// current map. The map has to be entered or closed afterwards.
+func (g *GameScene) openMap(mapPath string) (*openedMap, error) {
+	mapPath = path.Clean(mapPath)
+
+	tilemapJSON, err := g.assets.Map(mapPath)
+	if err != nil {
+		return nil, err
+	}
+
+	tilesets, world, err := g.prepareMap(tilemapJSON, mapPath)
+	if err != nil {
+		g.assets.ReleaseMap(mapPath)
+		return nil, err
+	}
+
+	objects := tilemapJSON.Objects()
+	if world != nil {
+		objects = append(objects, world.Objects()...)
+	}
+	return &openedMap{
+		path:         mapPath,
+		tilemapJSON:  tilemapJSON,
+		tilesets:     tilesets,
+		world:        world,
+		objectsLayer: tilemapJSON.LayerIndex(objectsLayerName),
+		objects:      objects,
+	}, nil
+}
+
+// closeMap gives back an opened map that isn't going to be entered
+func (g *GameScene) closeMap(opened *openedMap) {
+	g.assets.ReleaseMap(opened.path)
+}
+
+// enterMap unloads the current map, keeping its state, and makes an
+// opened map the current one
+func (g *GameScene) enterMap(opened *openedMap) {
+	if g.tilemapJSON != nil {
+		if g.world != nil {
+			g.unloadChunks(g.world.UnloadAll())
+		}
+		g.mapStates[g.mapPath] = &mapState{
+			enemies: g.enemies,
+			potions: g.potions,
+			chunks:  g.chunkStates,
+		}
+		g.renderer.Dispose()
+		g.assets.ReleaseMap(g.mapPath)
+	}
+
+	g.mapPath = opened.path
+	g.tilemapJSON = opened.tilemapJSON
+	g.tilesets = opened.tilesets
+	g.renderer = tilemap.NewRenderer(opened.tilemapJSON, opened.tilesets)
+	g.objectsLayer = opened.objectsLayer
+	g.objects = opened.objects
+	g.world = opened.world
+	g.chunkStates = make(map[image.Point]*mapState)
+
+	state, visited := g.mapStates[opened.path]
+	g.spawnObjects(opened.objects, !visited)
+	if visited {
+		g.enemies = state.enemies
+		g.potions = state.potions
+		if state.chunks != nil {
+			g.chunkStates = state.chunks
+		}
+		delete(g.mapStates, opened.path)
+	}
+	g.updateColliders()
+}
+
+// prepareMap loads the tilesets and images of a map, checks its tiles and
+// sets up its generated world if it has one
+func (g *GameScene) prepareMap(tilemapJSON *tilemap.TilemapJSON, mapPath string) (tilemap.Tilesets, *worldgen.World, error) {
+	tilesets, err := tilemapJSON.GenTilesets()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = tilemapJSON.LoadImages()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = tilemapJSON.Validate(tilesets)
+	if err != nil {
+		return nil, nil, assets.LoadError(mapPath, err)
+	}
+
+	objectsLayer := tilemapJSON.LayerIndex(objectsLayerName)
+	world, err := g.newWorld(tilemapJSON, objectsLayer, path.Dir(mapPath))
+	if err != nil {
+		return nil, nil, assets.LoadError(mapPath, err)
+	}
+	return tilesets, world, nil
+}
+
+// newWorld sets up the generated world of a map that has a seed property,
+// streamed into its ground and objects layers. Other maps get none.
+// Landmark objects of the map place the maps named by their map property,
+// relative to dir, into the world.
+func (g *GameScene) newWorld(tm *tilemap.TilemapJSON, objectsLayer int, dir string) (*worldgen.World, error) {
+	if !tm.Properties.Has("seed") {
+		return nil, nil
+	}
+
+	groundLayer := tm.LayerIndex(groundLayerName)
+	if groundLayer < 0 {
+		return nil, fmt.Errorf("generated map has no %q layer", groundLayerName)
+	}
+	gen, err := worldgen.NewGenerator(int64(tm.Properties.Int("seed", 0)), tm)
+	if err != nil {
+		return nil, err
+	}
+
+	tileWidth, tileHeight := tm.TileSize()
+	for _, object := range tm.Objects() {
+		if object.Class != "landmark" {
+			continue
+		}
+		target := object.Properties.String("map", "")
+		if target == "" {
+			return nil, fmt.Errorf("landmark %d has no map", object.Id)
+		}
+
+		//the landmark copies what it needs, so the map can go right away
+		landmarkPath := path.Join(dir, target)
+		landmarkMap, err := g.assets.Map(landmarkPath)
+		if err != nil {
+			return nil, err
+		}
+		landmark, err := worldgen.NewLandmark(
+			tm,
+			landmarkMap,
+			image.Pt(
+				int(math.Floor(object.X/float64(tileWidth))),
+				int(math.Floor(object.Y/float64(tileHeight))),
+			),
+			landmarkMap.LayerIndex(objectsLayerName),
+		)
+		g.assets.ReleaseMap(landmarkPath)
+		if err != nil {
+			return nil, fmt.Errorf("landmark %s: %w", target, err)
+		}
+		gen.AddLandmark(landmark)
+	}
+
+	return worldgen.NewWorld(gen, tm, groundLayer, objectsLayer), nil
+}
+
+// streamWorld loads the generated chunks around the camera and unloads
+// the far ones, along with their enemies and potions
+func (g *GameScene) streamWorld() {
+	view := image.Rect(int(-g.cam.X), int(-g.cam.Y), int(-g.cam.X)+320, int(-g.cam.Y)+240)
+	loaded, unloaded := g.world.Update(view)
+	if len(loaded) == 0 && len(unloaded) == 0 {
+		return
+	}
+
+	g.unloadChunks(unloaded)
+	for _, chunk := range loaded {
+		for index := range g.tilemapJSON.Layers {
+			g.renderer.Invalidate(index, chunk.Bounds())
+		}
+
+		//chunks seen before come back the way they were left
+		position := image.Pt(chunk.X, chunk.Y)
+		if state, ok := g.chunkStates[position]; ok {
+			g.enemies = append(g.enemies, state.enemies...)
+			g.potions = append(g.potions, state.potions...)
+			delete(g.chunkStates, position)
+			continue
+		}
+		for _, object := range chunk.Spawns {
+			g.spawnEntity(object)
+		}
+	}
+	g.updateColliders()
+}
+
+// unloadChunks frees the cached images of unloaded chunks and puts away
+// the enemies and potions standing in them until they are loaded again
+func (g *GameScene) unloadChunks(chunks []*worldgen.Chunk) {
+	tileWidth, tileHeight := g.tilemapJSON.TileSize()
+	for _, chunk := range chunks {
+		bounds := chunk.Bounds()
+		for index := range g.tilemapJSON.Layers {
+			g.renderer.Evict(index, bounds)
+		}
+
+		area := image.Rect(
+			bounds.Min.X*tileWidth,
+			bounds.Min.Y*tileHeight,
+			bounds.Max.X*tileWidth,
+			bounds.Max.Y*tileHeight,
+		)
+		state := &mapState{
+			enemies: make([]*entities.Enemy, 0),
+			potions: make([]*entities.Potion, 0),
+		}
+
+		enemies := make([]*entities.Enemy, 0, len(g.enemies))
+		for _, enemy := range g.enemies {
+			if image.Pt(int(enemy.X), int(enemy.Y)).In(area) {
+				state.enemies = append(state.enemies, enemy)
+			} else {
+				enemies = append(enemies, enemy)
+			}
+		}
+		g.enemies = enemies
+
+		potions := make([]*entities.Potion, 0, len(g.potions))
+		for _, potion := range g.potions {
+			if image.Pt(int(potion.X), int(potion.Y)).In(area) {
+				state.potions = append(state.potions, potion)
+			} else {
+				potions = append(potions, potion)
+			}
+		}
+		g.potions = potions
+
+		g.chunkStates[image.Pt(chunk.X, chunk.Y)] = state
+	}
+}
+
+// movePlayer puts the player at a spawn point
+func (g *GameScene) movePlayer(spawn tiled.Object) {
+	g.player.X = spawn.X
+	g.player.Y = spawn.Y
+	g.player.Dx = 0
+	g.player.Dy = 0
+	g.cam.Reset()
+
+	//triggers under the spawn point only fire once the player
+	//has stepped off them, so a portal can't send them right back
+	g.settleTriggers()
+}
+
+// findSpawn returns the object of a map, landmarks included, with the
+// given name, or the map's player spawn if the name is empty
+func findSpawn(objects []tiled.Object, mapPath, spawn string) (tiled.Object, error) {
+	for _, object := range objects {
+		if (spawn == "" && object.Class == "player") || (spawn != "" && object.Name == spawn) {
+			return object, nil
+		}
+	}
+
+	if spawn == "" {
+		return tiled.Object{}, assets.LoadError(mapPath, errors.New("no player spawn"))
+	}
+	return tiled.Object{}, assets.LoadError(mapPath, fmt.Errorf("no spawn point %q", spawn))
+}
+
+// settleTriggers marks the triggers the player stands in as entered
+// without firing them
+func (g *GameScene) settleTriggers() {
+	for _, trigger := range g.triggers {
+		trigger.entered = trigger.Contains(
+			g.player.X+constants.Tilesize/2,
+			g.player.Y+constants.Tilesize/2,
+		)
+	}
+}
+
+// usePortal takes the player to the map and spawn point named by the
+// portal's map and spawn properties. The map path is relative to the map
+// the portal is in. Nothing changes if either can't be found.
+func (g *GameScene) usePortal(portal tiled.Object) error {
+	target := portal.Properties.String("map", "")
+	if target == "" {
+		return assets.LoadError(g.mapPath, fmt.Errorf("portal %d has no target map", portal.Id))
+	}
+
+	opened, err := g.openMap(path.Join(path.Dir(g.mapPath), target))
+	if err != nil {
+		return err
+	}
+	//a bad spawn point leaves the player where they are
+	spawn, err := findSpawn(opened.objects, opened.path, portal.Properties.String("spawn", ""))
+	if err != nil {
+		g.closeMap(opened)
+		return err
+	}
+	g.enterMap(opened)
+	g.movePlayer(spawn)
+	return nil
+}
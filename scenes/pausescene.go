@@ -23,8 +23,9 @@ func (s *PauseScene) Draw(screen *ebiten.Image) {
 	ebitenutil.DebugPrint(screen, "Press enter to unpause.")
 }
 
-func (s *PauseScene) FirstLoad() {
+func (s *PauseScene) FirstLoad() error {
 	s.loaded = true
+	return nil
 }
 
 func (s *PauseScene) IsLoaded() bool {
@@ -39,11 +40,11 @@ func (s *PauseScene) OnExit() {
 
 }
 
-func (s *PauseScene) Update() SceneId {
+func (s *PauseScene) Update() (SceneId, error) {
 	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
-		return GameSceneId
+		return GameSceneId, nil
 	}
-	return PauseSceneId
+	return PauseSceneId, nil
 }
 
 var _ Scene = (*PauseScene)(nil)
@@ -0,0 +1,113 @@
+package scenes
+
+import (
+	"EndlessJourney/assets"
+	"path"
+	"sync"
+)
+
+// preloader loads assets on parallel goroutines ahead of a scene using
+// them, so that the scene finds them cached. It holds a reference to every
+// asset it loaded until released.
+type preloader struct {
+	assets   *assets.Manager
+	progress *Progress
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	err      error
+	images   []string
+	tilesets []string
+	maps     []string
+}
+
+func newPreloader(assets *assets.Manager, progress *Progress) *preloader {
+	return &preloader{
+		assets:   assets,
+		progress: progress,
+	}
+}
+
+func (p *preloader) image(name string) {
+	p.load(func() error {
+		_, err := p.assets.Image(name)
+		if err == nil {
+			p.keep(&p.images, name)
+		}
+		return err
+	})
+}
+
+func (p *preloader) tileset(name string) {
+	p.load(func() error {
+		_, err := p.assets.Tileset(name)
+		if err == nil {
+			p.keep(&p.tilesets, name)
+		}
+		return err
+	})
+}
+
+// gameMap loads a map, then its tilesets and the maps of its landmarks
+// along with theirs
+func (p *preloader) gameMap(name string) {
+	p.load(func() error {
+		tm, err := p.assets.Map(name)
+		if err != nil {
+			return err
+		}
+		p.keep(&p.maps, name)
+
+		for _, tilesetPath := range tm.TilesetPaths() {
+			p.tileset(tilesetPath)
+		}
+		for _, object := range tm.Objects() {
+			target := object.Properties.String("map", "")
+			if object.Class == "landmark" && target != "" {
+				p.gameMap(path.Join(path.Dir(name), target))
+			}
+		}
+		return nil
+	})
+}
+
+// load runs a step on its own goroutine, keeping the first error
+func (p *preloader) load(step func() error) {
+	p.progress.Add(1)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		err := step()
+		p.progress.Done()
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if err != nil && p.err == nil {
+			p.err = err
+		}
+	}()
+}
+
+func (p *preloader) keep(names *[]string, name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	*names = append(*names, name)
+}
+
+// wait waits for everything to load, returning the first error
+func (p *preloader) wait() error {
+	p.wg.Wait()
+	return p.err
+}
+
+// release gives back the references to what was loaded
+func (p *preloader) release() {
+	for _, name := range p.maps {
+		p.assets.ReleaseMap(name)
+	}
+	for _, name := range p.tilesets {
+		p.assets.ReleaseTileset(name)
+	}
+	for _, name := range p.images {
+		p.assets.ReleaseImage(name)
+	}
+}
@@ -8,13 +8,17 @@ const (
 	GameSceneId SceneId = iota
 	StartSceneId
 	PauseSceneId
+	ErrorSceneId
+	LoadingSceneId
 	ExitSceneId
 )
 
+// Scene is a screen of the game. Errors from Update and FirstLoad, such
+// as an asset that fails to load, take the game to the error scene.
 type Scene interface {
-	Update() SceneId
+	Update() (SceneId, error)
 	Draw(screen *ebiten.Image)
-	FirstLoad()
+	FirstLoad() error
 	OnEnter()
 	OnExit()
 	IsLoaded() bool
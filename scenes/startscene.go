@@ -23,8 +23,9 @@ func (s *StartScene) Draw(screen *ebiten.Image) {
 	ebitenutil.DebugPrint(screen, "Press enter to start.")
 }
 
-func (s *StartScene) FirstLoad() {
+func (s *StartScene) FirstLoad() error {
 	s.loaded = true
+	return nil
 }
 
 func (s *StartScene) IsLoaded() bool {
@@ -39,11 +40,11 @@ func (s *StartScene) OnExit() {
 
 }
 
-func (s *StartScene) Update() SceneId {
+func (s *StartScene) Update() (SceneId, error) {
 	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
-		return GameSceneId
+		return GameSceneId, nil
 	}
-	return StartSceneId
+	return StartSceneId, nil
 }
 
 var _ Scene = (*StartScene)(nil)
@@ -0,0 +1,29 @@
+package tiled
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// ParseColor reads a color as tiled writes it, either #rrggbb or #aarrggbb
+func ParseColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return color.NRGBA{}, fmt.Errorf("tiled: bad color %q", s)
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("tiled: bad color %q", s)
+	}
+	if len(hex) == 6 {
+		value |= 0xff000000
+	}
+	return color.NRGBA{
+		A: uint8(value >> 24),
+		R: uint8(value >> 16),
+		G: uint8(value >> 8),
+		B: uint8(value),
+	}, nil
+}
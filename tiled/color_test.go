@@ -0,0 +1,41 @@
+package tiled
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    color.NRGBA
+		wantErr bool
+	}{
+		{in: "#ff8000", want: color.NRGBA{R: 0xff, G: 0x80, B: 0x00, A: 0xff}},
+		{in: "ff8000", want: color.NRGBA{R: 0xff, G: 0x80, B: 0x00, A: 0xff}},
+		{in: "#80ff8000", want: color.NRGBA{R: 0xff, G: 0x80, B: 0x00, A: 0x80}},
+		{in: "#00123456", want: color.NRGBA{R: 0x12, G: 0x34, B: 0x56, A: 0x00}},
+		{in: "#ABCDEF", want: color.NRGBA{R: 0xab, G: 0xcd, B: 0xef, A: 0xff}},
+		{in: "", wantErr: true},
+		{in: "#fff", wantErr: true},
+		{in: "#ff80000", wantErr: true},
+		{in: "#gg8000", wantErr: true},
+		{in: "#-f8000", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseColor(test.in)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("ParseColor(%q) = %v, want an error", test.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseColor(%q): %v", test.in, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseColor(%q) = %v, want %v", test.in, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package tiled
+
+import (
+	"encoding/json"
+	"image"
+	"math"
+)
+
+// Shape is the geometry of an object placed in tiled
+type Shape uint8
+
+const (
+	Rectangle Shape = iota
+	Point
+	Ellipse
+	Polygon
+	Polyline
+)
+
+type ObjectPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type Object struct {
+	Id         int        `json:"id"`
+	Name       string     `json:"name"`
+	Class      string     `json:"type"`
+	X          float64    `json:"x"`
+	Y          float64    `json:"y"`
+	Width      float64    `json:"width"`
+	Height     float64    `json:"height"`
+	Rotation   float64    `json:"rotation"`
+	Visible    bool       `json:"visible"`
+	Properties Properties `json:"properties"`
+	Shape      Shape      `json:"-"`
+	// polygon and polyline points, relative to X and Y
+	Points []ObjectPoint `json:"-"`
+}
+
+// UnmarshalJSON works out the object's shape from the flags tiled sets
+func (o *Object) UnmarshalJSON(data []byte) error {
+	type objectJSON Object
+	aux := struct {
+		*objectJSON
+		Class    string        `json:"class"`
+		Point    bool          `json:"point"`
+		Ellipse  bool          `json:"ellipse"`
+		Polygon  []ObjectPoint `json:"polygon"`
+		Polyline []ObjectPoint `json:"polyline"`
+	}{
+		objectJSON: (*objectJSON)(o),
+	}
+	o.Visible = true
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	// newer versions of tiled call the type a class
+	if o.Class == "" {
+		o.Class = aux.Class
+	}
+
+	switch {
+	case aux.Point:
+		o.Shape = Point
+	case aux.Ellipse:
+		o.Shape = Ellipse
+	case aux.Polygon != nil:
+		o.Shape = Polygon
+		o.Points = aux.Polygon
+	case aux.Polyline != nil:
+		o.Shape = Polyline
+		o.Points = aux.Polyline
+	default:
+		o.Shape = Rectangle
+	}
+	return nil
+}
+
+// Bounds returns the pixel bounding box of the object, ignoring rotation.
+// Points have an empty bounding box at their position.
+func (o *Object) Bounds() image.Rectangle {
+	if o.Shape != Polygon && o.Shape != Polyline {
+		return image.Rect(
+			int(o.X), int(o.Y), int(o.X+o.Width), int(o.Y+o.Height),
+		)
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, point := range o.Points {
+		minX = math.Min(minX, point.X)
+		minY = math.Min(minY, point.Y)
+		maxX = math.Max(maxX, point.X)
+		maxY = math.Max(maxY, point.Y)
+	}
+	if len(o.Points) == 0 {
+		minX, minY, maxX, maxY = 0, 0, 0, 0
+	}
+	return image.Rect(
+		int(o.X+minX), int(o.Y+minY), int(o.X+maxX), int(o.Y+maxY),
+	)
+}
+
+// Contains reports whether the pixel position lies inside the object
+func (o *Object) Contains(x, y float64) bool {
+	switch o.Shape {
+	case Point, Polyline:
+		return false
+	case Ellipse:
+		if o.Width <= 0 || o.Height <= 0 {
+			return false
+		}
+		rx, ry := o.Width/2, o.Height/2
+		dx := (x - o.X - rx) / rx
+		dy := (y - o.Y - ry) / ry
+		return dx*dx+dy*dy <= 1
+	case Polygon:
+		// even-odd rule
+		inside := false
+		x -= o.X
+		y -= o.Y
+		for i, j := 0, len(o.Points)-1; i < len(o.Points); j, i = i, i+1 {
+			a, b := o.Points[i], o.Points[j]
+			if (a.Y > y) != (b.Y > y) &&
+				x < (b.X-a.X)*(y-a.Y)/(b.Y-a.Y)+a.X {
+				inside = !inside
+			}
+		}
+		return inside
+	default:
+		return x >= o.X && x < o.X+o.Width && y >= o.Y && y < o.Y+o.Height
+	}
+}
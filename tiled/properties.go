@@ -0,0 +1,62 @@
+package tiled
+
+// Property is a custom property set on a map, layer, object or tile
+type Property struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+type Properties []Property
+
+func (p Properties) Get(name string) (any, bool) {
+	for _, property := range p {
+		if property.Name == name {
+			return property.Value, true
+		}
+	}
+	return nil, false
+}
+
+func (p Properties) Has(name string) bool {
+	_, ok := p.Get(name)
+	return ok
+}
+
+// String returns the named property or def if it is missing or not a string
+func (p Properties) String(name string, def string) string {
+	if value, ok := p.Get(name); ok {
+		if s, ok := value.(string); ok {
+			return s
+		}
+	}
+	return def
+}
+
+// Float returns the named property or def if it is missing or not a number
+func (p Properties) Float(name string, def float64) float64 {
+	if value, ok := p.Get(name); ok {
+		switch v := value.(type) {
+		case float64:
+			return v
+		case int:
+			return float64(v)
+		}
+	}
+	return def
+}
+
+// Int returns the named property or def if it is missing or not a number
+func (p Properties) Int(name string, def int) int {
+	return int(p.Float(name, float64(def)))
+}
+
+// Bool returns the named property or def if it is missing or not a bool
+func (p Properties) Bool(name string, def bool) bool {
+	if value, ok := p.Get(name); ok {
+		if b, ok := value.(bool); ok {
+			return b
+		}
+	}
+	return def
+}
@@ -0,0 +1,146 @@
+package tiled
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+type propertyXML struct {
+	Name       string        `xml:"name,attr"`
+	Type       string        `xml:"type,attr"`
+	Value      *string       `xml:"value,attr"`
+	Text       string        `xml:",chardata"`
+	Properties []propertyXML `xml:"properties>property"`
+}
+
+// value converts the property to the value the json format would hold
+func (p *propertyXML) value() any {
+	if p.Type == "class" {
+		members := make(map[string]any)
+		for _, member := range p.Properties {
+			members[member.Name] = member.value()
+		}
+		return members
+	}
+
+	// multiline strings are written as text instead of an attribute
+	raw := p.Text
+	if p.Value != nil {
+		raw = *p.Value
+	}
+
+	switch p.Type {
+	case "int", "float", "object":
+		if f, err := strconv.ParseFloat(raw, 64); err == nil {
+			return f
+		}
+	case "bool":
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b
+		}
+	}
+	return raw
+}
+
+func (p *Properties) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var aux struct {
+		Properties []propertyXML `xml:"property"`
+	}
+	err := d.DecodeElement(&aux, &start)
+	if err != nil {
+		return err
+	}
+
+	for _, property := range aux.Properties {
+		propertyType := property.Type
+		if propertyType == "" {
+			propertyType = "string"
+		}
+		*p = append(*p, Property{
+			Name:  property.Name,
+			Type:  propertyType,
+			Value: property.value(),
+		})
+	}
+	return nil
+}
+
+type pointsXML struct {
+	Points string `xml:"points,attr"`
+}
+
+// parse reads tiled's "x1,y1 x2,y2 ..." point lists
+func (p *pointsXML) parse() []ObjectPoint {
+	points := make([]ObjectPoint, 0)
+	for _, pair := range strings.Fields(p.Points) {
+		x, y, _ := strings.Cut(pair, ",")
+		px, _ := strconv.ParseFloat(x, 64)
+		py, _ := strconv.ParseFloat(y, 64)
+		points = append(points, ObjectPoint{X: px, Y: py})
+	}
+	return points
+}
+
+func (o *Object) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	aux := struct {
+		Id         int        `xml:"id,attr"`
+		Name       string     `xml:"name,attr"`
+		Type       string     `xml:"type,attr"`
+		Class      string     `xml:"class,attr"`
+		X          float64    `xml:"x,attr"`
+		Y          float64    `xml:"y,attr"`
+		Width      float64    `xml:"width,attr"`
+		Height     float64    `xml:"height,attr"`
+		Rotation   float64    `xml:"rotation,attr"`
+		Visible    *int       `xml:"visible,attr"`
+		Properties Properties `xml:"properties"`
+		Point      *struct{}  `xml:"point"`
+		Ellipse    *struct{}  `xml:"ellipse"`
+		Polygon    *pointsXML `xml:"polygon"`
+		Polyline   *pointsXML `xml:"polyline"`
+	}{}
+	err := d.DecodeElement(&aux, &start)
+	if err != nil {
+		return err
+	}
+
+	*o = Object{
+		Id:         aux.Id,
+		Name:       aux.Name,
+		Class:      aux.Type,
+		X:          aux.X,
+		Y:          aux.Y,
+		Width:      aux.Width,
+		Height:     aux.Height,
+		Rotation:   aux.Rotation,
+		Visible:    aux.Visible == nil || *aux.Visible != 0,
+		Properties: aux.Properties,
+		Shape:      Rectangle,
+	}
+	if o.Class == "" {
+		o.Class = aux.Class
+	}
+
+	switch {
+	case aux.Point != nil:
+		o.Shape = Point
+	case aux.Ellipse != nil:
+		o.Shape = Ellipse
+	case aux.Polygon != nil:
+		o.Shape = Polygon
+		o.Points = aux.Polygon.parse()
+	case aux.Polyline != nil:
+		o.Shape = Polyline
+		o.Points = aux.Polyline.parse()
+	}
+	return nil
+}
+
+// IsXML reports whether file contents look like tiled's xml formats
+// rather than json
+func IsXML(contents []byte) bool {
+	trimmed := bytes.TrimSpace(contents)
+	return len(trimmed) > 0 && trimmed[0] == '<'
+}
@@ -0,0 +1,139 @@
+package tilemap
+
+import "image"
+
+// tiled's default chunk size, used when painting outside every chunk
+const defaultChunkSize = 16
+
+// Chunk is a rectangular block of a tile layer. Finite maps store each
+// layer as a single chunk at the origin, infinite maps as many chunks
+// that may sit at negative coordinates. Positions are in tiles. Data is
+// kept as the map file has it, so in a broken map it may not fill the
+// chunk, and cells it doesn't reach are empty.
+type Chunk struct {
+	X, Y          int
+	Width, Height int
+	Data          []int
+	Flips         []Flip
+}
+
+// newChunk builds a chunk from raw gids as tiled stores them
+func newChunk(x, y, width, height int, raw []uint32) *Chunk {
+	chunk := &Chunk{
+		X:      x,
+		Y:      y,
+		Width:  width,
+		Height: height,
+		Data:   make([]int, len(raw)),
+		Flips:  make([]Flip, len(raw)),
+	}
+	for index, gid := range raw {
+		chunk.Data[index], chunk.Flips[index] = DecodeGID(gid)
+	}
+	return chunk
+}
+
+func (c *Chunk) Bounds() image.Rectangle {
+	return image.Rect(c.X, c.Y, c.X+c.Width, c.Y+c.Height)
+}
+
+// Bounds returns the area covered by the layer's chunks, in tiles
+func (t *TilemapLayerJSON) Bounds() image.Rectangle {
+	bounds := image.Rectangle{}
+	for _, chunk := range t.Chunks {
+		bounds = bounds.Union(chunk.Bounds())
+	}
+	return bounds
+}
+
+// Tile returns the gid and flip of the tile at a tile position, or a gid
+// of 0 if nothing is painted there
+func (t *TilemapLayerJSON) Tile(x, y int) (int, Flip) {
+	for _, chunk := range t.Chunks {
+		if !image.Pt(x, y).In(chunk.Bounds()) {
+			continue
+		}
+		index := (y-chunk.Y)*chunk.Width + (x - chunk.X)
+		if index >= len(chunk.Data) {
+			break
+		}
+		return chunk.Data[index], chunk.Flips[index]
+	}
+	return 0, 0
+}
+
+// ForEachTile calls fn with the tile position, gid and flip of every
+// painted tile in the layer
+func (t *TilemapLayerJSON) ForEachTile(fn func(x, y, gid int, flip Flip)) {
+	for _, chunk := range t.Chunks {
+		for index, id := range chunk.Data {
+			//data past the end of a chunk that is too long belongs to no cell
+			if index >= chunk.Width*chunk.Height {
+				break
+			}
+			if id == 0 {
+				continue
+			}
+			fn(chunk.X+index%chunk.Width, chunk.Y+index/chunk.Width, id, chunk.Flips[index])
+		}
+	}
+}
+
+// SetTile paints a tile at a tile position. Painting outside the layer's
+// chunks adds a new chunk for the position.
+func (t *TilemapLayerJSON) SetTile(x, y, gid int, flip Flip) {
+	for _, chunk := range t.Chunks {
+		if !image.Pt(x, y).In(chunk.Bounds()) {
+			continue
+		}
+		index := (y-chunk.Y)*chunk.Width + (x - chunk.X)
+		if index >= len(chunk.Data) {
+			return
+		}
+		chunk.Data[index] = gid
+		chunk.Flips[index] = flip
+		return
+	}
+
+	chunk := newChunk(
+		floorDiv(x, defaultChunkSize)*defaultChunkSize,
+		floorDiv(y, defaultChunkSize)*defaultChunkSize,
+		defaultChunkSize,
+		defaultChunkSize,
+		make([]uint32, defaultChunkSize*defaultChunkSize),
+	)
+	t.Chunks = append(t.Chunks, chunk)
+	t.SetTile(x, y, gid, flip)
+}
+
+// SetChunk adds a chunk to the layer, replacing any chunk at the same
+// position, for layers that are filled in as the map is explored
+func (t *TilemapLayerJSON) SetChunk(chunk *Chunk) {
+	for index, old := range t.Chunks {
+		if old.X == chunk.X && old.Y == chunk.Y {
+			t.Chunks[index] = chunk
+			return
+		}
+	}
+	t.Chunks = append(t.Chunks, chunk)
+}
+
+// RemoveChunk removes the chunk at a tile position, if there is one
+func (t *TilemapLayerJSON) RemoveChunk(x, y int) {
+	for index, chunk := range t.Chunks {
+		if chunk.X == x && chunk.Y == y {
+			t.Chunks = append(t.Chunks[:index], t.Chunks[index+1:]...)
+			return
+		}
+	}
+}
+
+// floorDiv divides rounding towards negative infinity, so that negative
+// tile positions land in the right chunk
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
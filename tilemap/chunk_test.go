@@ -0,0 +1,132 @@
+package tilemap
+
+import (
+	"image"
+	"testing"
+)
+
+func TestFloorDiv(t *testing.T) {
+	tests := []struct {
+		a, b, want int
+	}{
+		{0, 16, 0},
+		{15, 16, 0},
+		{16, 16, 1},
+		{-1, 16, -1},
+		{-16, 16, -1},
+		{-17, 16, -2},
+		{5, -16, -1},
+		{-5, -16, 0},
+	}
+	for _, test := range tests {
+		if got := floorDiv(test.a, test.b); got != test.want {
+			t.Errorf("floorDiv(%d, %d) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestMisfitChunk(t *testing.T) {
+	tests := []struct {
+		name  string
+		raw   []uint32
+		tiles int
+	}{
+		{"exact", []uint32{1, 2, 3, 4, 5, 6}, 6},
+		{"short", []uint32{1, 2, 3, 4, 5}, 5},
+		{"long", []uint32{1, 2, 3, 4, 5, 6, 7}, 6},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			layer := &TilemapLayerJSON{Chunks: []*Chunk{newChunk(-16, 0, 3, 2, test.raw)}}
+
+			tiles := 0
+			layer.ForEachTile(func(x, y, gid int, flip Flip) {
+				if !image.Pt(x, y).In(image.Rect(-16, 0, -13, 2)) {
+					t.Errorf("ForEachTile reported gid %d at %d,%d outside the chunk", gid, x, y)
+				}
+				tiles++
+			})
+			if tiles != test.tiles {
+				t.Errorf("ForEachTile reported %d tiles, want %d", tiles, test.tiles)
+			}
+
+			// cells past the end of short data are empty and can't be painted
+			layer.SetTile(-14, 1, 9, 0)
+			want := 0
+			if len(test.raw) >= 6 {
+				want = 9
+			}
+			if gid, _ := layer.Tile(-14, 1); gid != want {
+				t.Errorf("Tile after SetTile = %d, want %d", gid, want)
+			}
+		})
+	}
+}
+
+func TestSetTile(t *testing.T) {
+	tests := []struct {
+		name  string
+		x, y  int
+		chunk image.Point
+	}{
+		{"origin", 0, 0, image.Pt(0, 0)},
+		{"inside first chunk", 15, 15, image.Pt(0, 0)},
+		{"next chunk", 16, 3, image.Pt(16, 0)},
+		{"just left of the origin", -1, 0, image.Pt(-16, 0)},
+		{"just above the origin", 0, -1, image.Pt(0, -16)},
+		{"chunk corner", -16, -16, image.Pt(-16, -16)},
+		{"past a chunk corner", -17, -33, image.Pt(-32, -48)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			layer := &TilemapLayerJSON{}
+			layer.SetTile(test.x, test.y, 7, FlipVertical)
+
+			if len(layer.Chunks) != 1 {
+				t.Fatalf("SetTile added %d chunks, want 1", len(layer.Chunks))
+			}
+			chunk := layer.Chunks[0]
+			if got := image.Pt(chunk.X, chunk.Y); got != test.chunk {
+				t.Errorf("SetTile added a chunk at %v, want %v", got, test.chunk)
+			}
+			if gid, flip := layer.Tile(test.x, test.y); gid != 7 || flip != FlipVertical {
+				t.Errorf("Tile = %d, %b, want 7, %b", gid, flip, FlipVertical)
+			}
+			if gid, _ := layer.Tile(test.x+1, test.y); gid != 0 {
+				t.Errorf("Tile next to the painted one = %d, want 0", gid)
+			}
+
+			// painting again inside the chunk reuses it
+			layer.SetTile(test.chunk.X, test.chunk.Y, 9, 0)
+			if len(layer.Chunks) != 1 {
+				t.Errorf("second SetTile added a chunk, have %d", len(layer.Chunks))
+			}
+			if gid, _ := layer.Tile(test.chunk.X, test.chunk.Y); gid != 9 {
+				t.Errorf("Tile at the chunk corner = %d, want 9", gid)
+			}
+		})
+	}
+}
+
+func TestTileOutsideChunks(t *testing.T) {
+	layer := &TilemapLayerJSON{Chunks: []*Chunk{newChunk(-2, -2, 2, 2, []uint32{1, 2, 3, 0x80000000 | 4})}}
+
+	tests := []struct {
+		x, y int
+		gid  int
+		flip Flip
+	}{
+		{-2, -2, 1, 0},
+		{-1, -2, 2, 0},
+		{-2, -1, 3, 0},
+		{-1, -1, 4, FlipHorizontal},
+		{0, 0, 0, 0},
+		{-3, -1, 0, 0},
+	}
+	for _, test := range tests {
+		gid, flip := layer.Tile(test.x, test.y)
+		if gid != test.gid || flip != test.flip {
+			t.Errorf("Tile(%d, %d) = %d, %b, want %d, %b", test.x, test.y, gid, flip, test.gid, test.flip)
+		}
+	}
+}
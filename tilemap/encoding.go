@@ -0,0 +1,88 @@
+package tilemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// decodeGIDs turns layer data in one of tiled's text encodings into the
+// raw gids it holds, flip flags included
+func decodeGIDs(data, encoding, compression string) ([]uint32, error) {
+	switch encoding {
+	case "csv":
+		gids := make([]uint32, 0)
+		for _, field := range strings.Split(data, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			gid, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, err
+			}
+			gids = append(gids, uint32(gid))
+		}
+		return gids, nil
+	case "base64":
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+		if err != nil {
+			return nil, err
+		}
+		raw, err = decompress(raw, compression)
+		if err != nil {
+			return nil, err
+		}
+		if len(raw)%4 != 0 {
+			return nil, fmt.Errorf("tilemap: layer data is %d bytes, not a multiple of 4", len(raw))
+		}
+
+		//gids are stored as little endian uint32s
+		gids := make([]uint32, len(raw)/4)
+		for index := range gids {
+			gids[index] = binary.LittleEndian.Uint32(raw[index*4:])
+		}
+		return gids, nil
+	}
+	return nil, fmt.Errorf("tilemap: unknown layer encoding %q", encoding)
+}
+
+func decompress(raw []byte, compression string) ([]byte, error) {
+	var reader io.Reader
+	switch compression {
+	case "":
+		return raw, nil
+	case "zlib":
+		zlibReader, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		defer zlibReader.Close()
+		reader = zlibReader
+	case "gzip":
+		gzipReader, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	case "zstd":
+		zstdReader, err := zstd.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		defer zstdReader.Close()
+		reader = zstdReader
+	default:
+		return nil, fmt.Errorf("tilemap: unknown layer compression %q", compression)
+	}
+	return io.ReadAll(reader)
+}
@@ -0,0 +1,120 @@
+package tilemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// a tile flipped horizontally, an empty tile and a high plain gid
+var testGIDs = []uint32{0x80000000 | 5, 0, 1, 300}
+
+// encodeGIDs writes gids the way tiled does for base64 layer data
+func encodeGIDs(t *testing.T, gids []uint32, compression string) string {
+	t.Helper()
+	raw := make([]byte, len(gids)*4)
+	for index, gid := range gids {
+		binary.LittleEndian.PutUint32(raw[index*4:], gid)
+	}
+
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	switch compression {
+	case "":
+		return base64.StdEncoding.EncodeToString(raw)
+	case "zlib":
+		writer = zlib.NewWriter(&buf)
+	case "gzip":
+		writer = gzip.NewWriter(&buf)
+	case "zstd":
+		zstdWriter, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer = zstdWriter
+	}
+	if _, err := writer.Write(raw); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestDecodeGIDs(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		encoding    string
+		compression string
+		want        []uint32
+		wantErr     bool
+	}{
+		{name: "csv", data: "2147483653,0,\n1,300\n", encoding: "csv", want: testGIDs},
+		{name: "csv trailing comma", data: "1,2,", encoding: "csv", want: []uint32{1, 2}},
+		{name: "csv bad number", data: "1,x", encoding: "csv", wantErr: true},
+		{name: "base64", data: encodeGIDs(t, testGIDs, ""), encoding: "base64", want: testGIDs},
+		{name: "base64 padded with whitespace", data: "\n   " + encodeGIDs(t, testGIDs, "") + "\n", encoding: "base64", want: testGIDs},
+		{name: "zlib", data: encodeGIDs(t, testGIDs, "zlib"), encoding: "base64", compression: "zlib", want: testGIDs},
+		{name: "gzip", data: encodeGIDs(t, testGIDs, "gzip"), encoding: "base64", compression: "gzip", want: testGIDs},
+		{name: "zstd", data: encodeGIDs(t, testGIDs, "zstd"), encoding: "base64", compression: "zstd", want: testGIDs},
+		{name: "wrong compression", data: encodeGIDs(t, testGIDs, "zlib"), encoding: "base64", compression: "gzip", wantErr: true},
+		{name: "unknown compression", data: encodeGIDs(t, testGIDs, ""), encoding: "base64", compression: "lz4", wantErr: true},
+		{name: "partial gid", data: base64.StdEncoding.EncodeToString([]byte{1, 0, 0}), encoding: "base64", wantErr: true},
+		{name: "unknown encoding", data: "1,2", encoding: "hex", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gids, err := decodeGIDs(test.data, test.encoding, test.compression)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("decodeGIDs = %v, want an error", gids)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(gids, test.want) {
+				t.Errorf("decodeGIDs = %v, want %v", gids, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONGIDs(t *testing.T) {
+	encoded, err := json.Marshal(encodeGIDs(t, testGIDs, "zlib"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		data        string
+		encoding    string
+		compression string
+	}{
+		{name: "array", data: "[2147483653, 0, 1, 300]"},
+		{name: "explicit csv", data: "[2147483653, 0, 1, 300]", encoding: "csv"},
+		{name: "base64", data: string(encoded), encoding: "base64", compression: "zlib"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gids, err := decodeJSONGIDs(json.RawMessage(test.data), test.encoding, test.compression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(gids, testGIDs) {
+				t.Errorf("decodeJSONGIDs = %v, want %v", gids, testGIDs)
+			}
+		})
+	}
+}
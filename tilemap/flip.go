@@ -0,0 +1,92 @@
+package tilemap
+
+import (
+	"image"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Flip holds the transformations tiled applies to a placed tile
+type Flip uint8
+
+const (
+	FlipHorizontal Flip = 1 << iota
+	FlipVertical
+	FlipDiagonal
+	// rotation of tiles on hexagonal maps, which the renderer ignores
+	FlipHexagonal120
+)
+
+// tiled stores the flip flags in the highest bits of each gid
+const (
+	flippedHorizontallyFlag uint32 = 0x80000000
+	flippedVerticallyFlag   uint32 = 0x40000000
+	flippedDiagonallyFlag   uint32 = 0x20000000
+	rotatedHexagonal120Flag uint32 = 0x10000000
+
+	gidMask = ^(flippedHorizontallyFlag | flippedVerticallyFlag |
+		flippedDiagonallyFlag | rotatedHexagonal120Flag)
+)
+
+// DecodeGID splits a raw gid as written by tiled into the clean gid and
+// its flip flags
+func DecodeGID(raw uint32) (int, Flip) {
+	var flip Flip
+	if raw&flippedHorizontallyFlag != 0 {
+		flip |= FlipHorizontal
+	}
+	if raw&flippedVerticallyFlag != 0 {
+		flip |= FlipVertical
+	}
+	if raw&flippedDiagonallyFlag != 0 {
+		flip |= FlipDiagonal
+	}
+	if raw&rotatedHexagonal120Flag != 0 {
+		flip |= FlipHexagonal120
+	}
+	return int(raw & gidMask), flip
+}
+
+// Size returns the size of a w by h tile after the flip is applied
+func (f Flip) Size(w, h int) (int, int) {
+	if f&FlipDiagonal != 0 {
+		return h, w
+	}
+	return w, h
+}
+
+// Apply adds the flip to geo for a tile image of w by h pixels. The
+// flipped tile keeps its top left corner at the origin.
+func (f Flip) Apply(geo *ebiten.GeoM, w, h float64) {
+	if f&FlipDiagonal != 0 {
+		// swap the x and y axes, tiled applies this before the other flips
+		geo.Scale(1, -1)
+		geo.Rotate(math.Pi / 2)
+		w, h = h, w
+	}
+	if f&FlipHorizontal != 0 {
+		geo.Scale(-1, 1)
+		geo.Translate(w, 0)
+	}
+	if f&FlipVertical != 0 {
+		geo.Scale(1, -1)
+		geo.Translate(0, h)
+	}
+}
+
+// Rect moves a rectangle inside a w by h tile to where it ends up once
+// the tile is flipped
+func (f Flip) Rect(r image.Rectangle, w, h int) image.Rectangle {
+	if f&FlipDiagonal != 0 {
+		r = image.Rect(r.Min.Y, r.Min.X, r.Max.Y, r.Max.X)
+		w, h = h, w
+	}
+	if f&FlipHorizontal != 0 {
+		r = image.Rect(w-r.Max.X, r.Min.Y, w-r.Min.X, r.Max.Y)
+	}
+	if f&FlipVertical != 0 {
+		r = image.Rect(r.Min.X, h-r.Max.Y, r.Max.X, h-r.Min.Y)
+	}
+	return r
+}
@@ -0,0 +1,94 @@
+package tilemap
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+func TestDecodeGID(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  uint32
+		gid  int
+		flip Flip
+	}{
+		{"plain", 42, 42, 0},
+		{"empty", 0, 0, 0},
+		{"horizontal", 0x80000000 | 7, 7, FlipHorizontal},
+		{"vertical", 0x40000000 | 7, 7, FlipVertical},
+		{"diagonal", 0x20000000 | 7, 7, FlipDiagonal},
+		{"hexagonal", 0x10000000 | 7, 7, FlipHexagonal120},
+		{"rotated 90", 0xa0000000 | 3, 3, FlipDiagonal | FlipHorizontal},
+		{"rotated 180", 0xc0000000 | 3, 3, FlipHorizontal | FlipVertical},
+		{"all flags", 0xf0000000 | 0x0fffffff, 0x0fffffff, FlipHorizontal | FlipVertical | FlipDiagonal | FlipHexagonal120},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gid, flip := DecodeGID(test.raw)
+			if gid != test.gid || flip != test.flip {
+				t.Errorf("DecodeGID(%#x) = %d, %b, want %d, %b", test.raw, gid, flip, test.gid, test.flip)
+			}
+		})
+	}
+}
+
+func TestFlipRect(t *testing.T) {
+	// a 2x1 rectangle in the top left of a 16x8 tile
+	r := image.Rect(0, 0, 2, 1)
+	tests := []struct {
+		name string
+		flip Flip
+		want image.Rectangle
+	}{
+		{"none", 0, image.Rect(0, 0, 2, 1)},
+		{"horizontal", FlipHorizontal, image.Rect(14, 0, 16, 1)},
+		{"vertical", FlipVertical, image.Rect(0, 7, 2, 8)},
+		{"both", FlipHorizontal | FlipVertical, image.Rect(14, 7, 16, 8)},
+		{"diagonal", FlipDiagonal, image.Rect(0, 0, 1, 2)},
+		{"rotated 90", FlipDiagonal | FlipHorizontal, image.Rect(7, 0, 8, 2)},
+		{"rotated 270", FlipDiagonal | FlipVertical, image.Rect(0, 14, 1, 16)},
+		{"hexagonal only", FlipHexagonal120, image.Rect(0, 0, 2, 1)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.flip.Rect(r, 16, 8)
+			if got != test.want {
+				t.Errorf("Rect = %v, want %v", got, test.want)
+			}
+
+			// the drawn tile has to end up where Rect says it does
+			var geo ebiten.GeoM
+			test.flip.Apply(&geo, 16, 8)
+			x0, y0 := geo.Apply(float64(r.Min.X), float64(r.Min.Y))
+			x1, y1 := geo.Apply(float64(r.Max.X), float64(r.Max.Y))
+			drawn := image.Rect(
+				int(math.Round(x0)), int(math.Round(y0)),
+				int(math.Round(x1)), int(math.Round(y1)),
+			)
+			if drawn != test.want {
+				t.Errorf("Apply moves the rectangle to %v, want %v", drawn, test.want)
+			}
+		})
+	}
+}
+
+func TestFlipSize(t *testing.T) {
+	tests := []struct {
+		flip Flip
+		w, h int
+	}{
+		{0, 16, 8},
+		{FlipHorizontal | FlipVertical, 16, 8},
+		{FlipDiagonal, 8, 16},
+		{FlipDiagonal | FlipHorizontal, 8, 16},
+	}
+	for _, test := range tests {
+		w, h := test.flip.Size(16, 8)
+		if w != test.w || h != test.h {
+			t.Errorf("Flip(%b).Size(16, 8) = %d, %d, want %d, %d", test.flip, w, h, test.w, test.h)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package tilemap
+
+import (
+	"EndlessJourney/camera"
+	"image"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// tiles per side of a cached chunk image
+const cacheChunkSize = 16
+
+// cachedChunk is a square block of a tile layer pre-rendered into one
+// image. Animated tiles are left out of the image and drawn every frame,
+// from a list kept with the image so they aren't looked up again.
+type cachedChunk struct {
+	img *ebiten.Image
+	// pixel position of the image's top left corner in the map
+	origin   image.Point
+	animated []bakedTile
+	dirty    bool
+}
+
+// bakedTile is a tile found in a chunk when it was baked
+type bakedTile struct {
+	x, y, gid int
+	flip      Flip
+}
+
+// Renderer draws the tile layers of a map. Static tiles are baked into
+// chunk sized images the first time they come into view, so a frame costs
+// one draw per visible chunk instead of one per tile. With Cache turned
+// off tiles are drawn one by one, but only those in view.
+type Renderer struct {
+	Cache    bool
+	tilemap  *TilemapJSON
+	tilesets Tilesets
+	// cached chunks per layer, keyed by chunk position
+	caches []map[image.Point]*cachedChunk
+	// how far tiles can reach outside their cell, in pixels
+	margin image.Point
+}
+
+func NewRenderer(tilemap *TilemapJSON, tilesets Tilesets) *Renderer {
+	caches := make([]map[image.Point]*cachedChunk, len(tilemap.Layers))
+	for index := range caches {
+		caches[index] = make(map[image.Point]*cachedChunk)
+	}
+
+	tileWidth, tileHeight := tilemap.TileSize()
+	margin := image.Point{}
+	for _, ts := range tilesets {
+		w, h := ts.TileSize()
+		offset := ts.TileOffset()
+		margin.X = max(margin.X, w-tileWidth+abs(offset.X))
+		margin.Y = max(margin.Y, h-tileHeight+abs(offset.Y))
+	}
+
+	return &Renderer{
+		Cache:    true,
+		tilemap:  tilemap,
+		tilesets: tilesets,
+		caches:   caches,
+		margin:   margin,
+	}
+}
+
+// Draw draws every visible tile and image layer as seen through the camera
+func (r *Renderer) Draw(screen *ebiten.Image, cam *camera.Camera, viewWidth, viewHeight int) {
+	for index := range r.tilemap.Layers {
+		r.DrawLayer(screen, index, cam, viewWidth, viewHeight)
+	}
+}
+
+// DrawLayer draws the part of one tile or image layer that is in view
+func (r *Renderer) DrawLayer(screen *ebiten.Image, layerIndex int, cam *camera.Camera, viewWidth, viewHeight int) {
+	layer := &r.tilemap.Layers[layerIndex]
+	if layer.Type == ImageLayer {
+		r.drawImageLayer(screen, layer, cam, viewWidth, viewHeight)
+		return
+	}
+	if layer.Type != TileLayer || !layer.Visible || layer.Opacity <= 0 {
+		return
+	}
+	colorScale := layerColorScale(layer)
+	layerCam := LayerCamera(layer, cam)
+
+	if !r.Cache {
+		r.ForEachVisibleTile(layerIndex, cam, viewWidth, viewHeight, func(x, y, gid int, flip Flip) {
+			r.drawTile(screen, x, y, gid, flip, layerCam.X, layerCam.Y, colorScale)
+		})
+		return
+	}
+
+	view := viewRect(layerCam, viewWidth, viewHeight)
+
+	//chunk images can reach past their chunk for tiles bigger than a
+	//cell, so look one chunk further than the view in every direction
+	tileWidth, tileHeight := r.tilemap.TileSize()
+	chunkWidth, chunkHeight := cacheChunkSize*tileWidth, cacheChunkSize*tileHeight
+	chunks := image.Rect(
+		floorDiv(view.Min.X, chunkWidth)-1,
+		floorDiv(view.Min.Y, chunkHeight)-1,
+		floorDiv(view.Max.X-1, chunkWidth)+2,
+		floorDiv(view.Max.Y-1, chunkHeight)+2,
+	)
+	layerBounds := layer.Bounds()
+	chunks = chunks.Intersect(image.Rect(
+		floorDiv(layerBounds.Min.X, cacheChunkSize),
+		floorDiv(layerBounds.Min.Y, cacheChunkSize),
+		floorDiv(layerBounds.Max.X-1, cacheChunkSize)+1,
+		floorDiv(layerBounds.Max.Y-1, cacheChunkSize)+1,
+	))
+
+	opts := ebiten.DrawImageOptions{}
+	opts.ColorScale = colorScale
+	cache := r.caches[layerIndex]
+	for y := chunks.Min.Y; y < chunks.Max.Y; y++ {
+		for x := chunks.Min.X; x < chunks.Max.X; x++ {
+			chunk, ok := cache[image.Pt(x, y)]
+			if !ok {
+				chunk = &cachedChunk{dirty: true}
+				cache[image.Pt(x, y)] = chunk
+			}
+			if chunk.dirty {
+				r.bake(layer, image.Pt(x, y), chunk)
+			}
+
+			if chunk.img != nil && chunk.img.Bounds().Add(chunk.origin).Overlaps(view) {
+				opts.GeoM.Translate(float64(chunk.origin.X), float64(chunk.origin.Y))
+				opts.GeoM.Translate(layerCam.X, layerCam.Y)
+				screen.DrawImage(chunk.img, &opts)
+				opts.GeoM.Reset()
+			}
+
+			for _, tile := range chunk.animated {
+				if r.tileBounds(tile.x, tile.y, tile.gid, tile.flip).Overlaps(view) {
+					r.drawTile(screen, tile.x, tile.y, tile.gid, tile.flip, layerCam.X, layerCam.Y, colorScale)
+				}
+			}
+		}
+	}
+}
+
+// VisibleTiles returns the range of tile positions of a layer whose tiles
+// can show up in the view. The range reaches past the view by the margin
+// tiles bigger than a cell need, such as tall buildings whose cell is
+// below the view.
+func (r *Renderer) VisibleTiles(layerIndex int, cam *camera.Camera, viewWidth, viewHeight int) image.Rectangle {
+	tileWidth, tileHeight := r.tilemap.TileSize()
+	view := viewRect(LayerCamera(&r.tilemap.Layers[layerIndex], cam), viewWidth, viewHeight)
+	view.Min = view.Min.Sub(r.margin)
+	view.Max = view.Max.Add(r.margin)
+	return image.Rect(
+		floorDiv(view.Min.X, tileWidth),
+		floorDiv(view.Min.Y, tileHeight),
+		floorDiv(view.Max.X-1, tileWidth)+1,
+		floorDiv(view.Max.Y-1, tileHeight)+1,
+	)
+}
+
+// ForEachVisibleTile calls fn for every painted tile of a layer that can
+// show up in the view, in drawing order
+func (r *Renderer) ForEachVisibleTile(layerIndex int, cam *camera.Camera, viewWidth, viewHeight int, fn func(x, y, gid int, flip Flip)) {
+	visible := r.VisibleTiles(layerIndex, cam, viewWidth, viewHeight)
+	for _, chunk := range r.tilemap.Layers[layerIndex].Chunks {
+		area := chunk.Bounds().Intersect(visible)
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			for x := area.Min.X; x < area.Max.X; x++ {
+				index := (y-chunk.Y)*chunk.Width + (x - chunk.X)
+				if index >= len(chunk.Data) || chunk.Data[index] == 0 {
+					continue
+				}
+				fn(x, y, chunk.Data[index], chunk.Flips[index])
+			}
+		}
+	}
+}
+
+// DrawTile draws a single tile of a layer through the camera, for passes
+// that mix tiles with other things such as depth sorting
+func (r *Renderer) DrawTile(screen *ebiten.Image, layerIndex, x, y, gid int, flip Flip, cam *camera.Camera) {
+	layer := &r.tilemap.Layers[layerIndex]
+	layerCam := LayerCamera(layer, cam)
+	r.drawTile(screen, x, y, gid, flip, layerCam.X, layerCam.Y, layerColorScale(layer))
+}
+
+// SetTile paints a tile and redraws the chunk it falls into
+func (r *Renderer) SetTile(layerIndex, x, y, gid int, flip Flip) {
+	r.tilemap.Layers[layerIndex].SetTile(x, y, gid, flip)
+	r.Invalidate(layerIndex, image.Rect(x, y, x+1, y+1))
+}
+
+// Invalidate marks the chunks overlapping an area of the layer, given in
+// tiles, to be redrawn the next time they are visible
+func (r *Renderer) Invalidate(layerIndex int, area image.Rectangle) {
+	for y := floorDiv(area.Min.Y, cacheChunkSize); y <= floorDiv(area.Max.Y-1, cacheChunkSize); y++ {
+		for x := floorDiv(area.Min.X, cacheChunkSize); x <= floorDiv(area.Max.X-1, cacheChunkSize); x++ {
+			if chunk, ok := r.caches[layerIndex][image.Pt(x, y)]; ok {
+				chunk.dirty = true
+			}
+		}
+	}
+}
+
+// Evict frees the cached chunks overlapping an area of the layer, given
+// in tiles, for parts of the map that are no longer needed
+func (r *Renderer) Evict(layerIndex int, area image.Rectangle) {
+	for y := floorDiv(area.Min.Y, cacheChunkSize); y <= floorDiv(area.Max.Y-1, cacheChunkSize); y++ {
+		for x := floorDiv(area.Min.X, cacheChunkSize); x <= floorDiv(area.Max.X-1, cacheChunkSize); x++ {
+			position := image.Pt(x, y)
+			if chunk, ok := r.caches[layerIndex][position]; ok {
+				if chunk.img != nil {
+					chunk.img.Deallocate()
+				}
+				delete(r.caches[layerIndex], position)
+			}
+		}
+	}
+}
+
+// Dispose frees the cached chunk images, for when the map is unloaded.
+// The renderer can still be used afterwards, chunks are baked again.
+func (r *Renderer) Dispose() {
+	for index, cache := range r.caches {
+		for _, chunk := range cache {
+			if chunk.img != nil {
+				chunk.img.Deallocate()
+			}
+		}
+		r.caches[index] = make(map[image.Point]*cachedChunk)
+	}
+}
+
+// bake renders the static tiles of a chunk into its image
+func (r *Renderer) bake(layer *TilemapLayerJSON, position image.Point, chunk *cachedChunk) {
+	static := make([]bakedTile, 0)
+	chunk.animated = chunk.animated[:0]
+	bounds := image.Rectangle{}
+	for y := position.Y * cacheChunkSize; y < (position.Y+1)*cacheChunkSize; y++ {
+		for x := position.X * cacheChunkSize; x < (position.X+1)*cacheChunkSize; x++ {
+			gid, flip := layer.Tile(x, y)
+			if gid == 0 {
+				continue
+			}
+			ts, id, err := r.tilesets.Resolve(gid)
+			if err != nil {
+				continue
+			}
+			if ts.Animation(id) != nil {
+				chunk.animated = append(chunk.animated, bakedTile{x, y, gid, flip})
+				continue
+			}
+			bounds = bounds.Union(r.tileBounds(x, y, gid, flip))
+			static = append(static, bakedTile{x, y, gid, flip})
+		}
+	}
+	chunk.dirty = false
+
+	//reuse the old image when the chunk still covers the same area
+	if chunk.img != nil && chunk.img.Bounds().Size() != bounds.Size() {
+		chunk.img.Deallocate()
+		chunk.img = nil
+	}
+	if bounds.Empty() {
+		return
+	}
+	if chunk.img == nil {
+		chunk.img = ebiten.NewImage(bounds.Dx(), bounds.Dy())
+	} else {
+		chunk.img.Clear()
+	}
+	chunk.origin = bounds.Min
+
+	for _, tile := range static {
+		r.drawTile(
+			chunk.img, tile.x, tile.y, tile.gid, tile.flip,
+			float64(-bounds.Min.X), float64(-bounds.Min.Y), ebiten.ColorScale{},
+		)
+	}
+}
+
+// tileGeoM places a tile image in the map. Tiles are anchored to the
+// bottom left of their cell, so tall tiles reach into the cells above.
+func (r *Renderer) tileGeoM(img *ebiten.Image, offset image.Point, x, y int, flip Flip) ebiten.GeoM {
+	tileWidth, tileHeight := r.tilemap.TileSize()
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+
+	geoM := ebiten.GeoM{}
+	//mirror and rotate the tile in place before moving it
+	flip.Apply(&geoM, float64(w), float64(h))
+	_, h = flip.Size(w, h)
+	geoM.Translate(
+		float64(x*tileWidth+offset.X),
+		float64((y+1)*tileHeight-h+offset.Y),
+	)
+	return geoM
+}
+
+// tileBounds returns the pixel area a tile covers in the map
+func (r *Renderer) tileBounds(x, y, gid int, flip Flip) image.Rectangle {
+	img, offset, err := r.tilesets.Tile(gid)
+	if err != nil {
+		return image.Rectangle{}
+	}
+	tileWidth, tileHeight := r.tilemap.TileSize()
+	w, h := flip.Size(img.Bounds().Dx(), img.Bounds().Dy())
+	return image.Rect(0, 0, w, h).Add(image.Pt(
+		x*tileWidth+offset.X,
+		(y+1)*tileHeight-h+offset.Y,
+	))
+}
+
+// drawTile draws a single tile, shifted by dx, dy
+func (r *Renderer) drawTile(dst *ebiten.Image, x, y, gid int, flip Flip, dx, dy float64, colorScale ebiten.ColorScale) {
+	img, offset, err := r.tilesets.Tile(gid)
+	if err != nil {
+		// unknown gids are reported when the map is loaded
+		return
+	}
+
+	opts := ebiten.DrawImageOptions{}
+	opts.GeoM = r.tileGeoM(img, offset, x, y, flip)
+	opts.GeoM.Translate(dx, dy)
+	opts.ColorScale = colorScale
+	dst.DrawImage(img, &opts)
+}
+
+// drawImageLayer draws the image of an image layer, repeating it across
+// the view if the layer asks for it
+func (r *Renderer) drawImageLayer(screen *ebiten.Image, layer *TilemapLayerJSON, cam *camera.Camera, viewWidth, viewHeight int) {
+	if layer.Img == nil || !layer.Visible || layer.Opacity <= 0 {
+		return
+	}
+	layerCam := LayerCamera(layer, cam)
+	w := float64(layer.Img.Bounds().Dx())
+	h := float64(layer.Img.Bounds().Dy())
+
+	//start repeating images just off the top left of the view
+	startX, startY := layerCam.X, layerCam.Y
+	if layer.RepeatX {
+		startX = math.Mod(startX, w)
+		if startX > 0 {
+			startX -= w
+		}
+	}
+	if layer.RepeatY {
+		startY = math.Mod(startY, h)
+		if startY > 0 {
+			startY -= h
+		}
+	}
+
+	opts := ebiten.DrawImageOptions{}
+	opts.ColorScale = layerColorScale(layer)
+	for y := startY; y < float64(viewHeight); y += h {
+		for x := startX; x < float64(viewWidth); x += w {
+			opts.GeoM.Translate(x, y)
+			screen.DrawImage(layer.Img, &opts)
+			opts.GeoM.Reset()
+			if !layer.RepeatX {
+				break
+			}
+		}
+		if !layer.RepeatY {
+			break
+		}
+	}
+}
+
+// LayerCamera returns the camera a layer is seen through once its offset
+// and parallax factors are applied
+func LayerCamera(layer *TilemapLayerJSON, cam *camera.Camera) *camera.Camera {
+	return camera.NewCamera(
+		cam.X*layer.ParallaxX+layer.OffsetX,
+		cam.Y*layer.ParallaxY+layer.OffsetY,
+	)
+}
+
+// layerColorScale combines the layer's tint and opacity
+func layerColorScale(layer *TilemapLayerJSON) ebiten.ColorScale {
+	colorScale := ebiten.ColorScale{}
+	colorScale.ScaleWithColor(layer.Tint)
+	colorScale.ScaleAlpha(float32(layer.Opacity))
+	return colorScale
+}
+
+// viewRect returns the area of the map the camera shows, in pixels
+func viewRect(cam *camera.Camera, viewWidth, viewHeight int) image.Rectangle {
+	return image.Rect(
+		int(-cam.X), int(-cam.Y), int(-cam.X)+viewWidth, int(-cam.Y)+viewHeight,
+	)
+}
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
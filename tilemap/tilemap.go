@@ -1,52 +1,463 @@
 package tilemap
 
 import (
+	"EndlessJourney/constants"
+	"EndlessJourney/tiled"
 	"EndlessJourney/tileset"
 	"encoding/json"
-	"os"
+	"fmt"
+	"image"
+	"image/color"
+	"io/fs"
 	"path"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
 )
 
 type TilemapLayerJSON struct {
-	Data   []int  `json:"data"`
-	Width  int    `json:"width"`
-	Height int    `json:"height"`
-	Name   string `json:"name"`
+	Chunks     []*Chunk           `json:"-"`
+	Width      int                `json:"width"`
+	Height     int                `json:"height"`
+	Name       string             `json:"name"`
+	Type       string             `json:"type"`
+	Objects    []tiled.Object     `json:"objects"`
+	Properties tiled.Properties   `json:"properties"`
+	Visible    bool               `json:"visible"`
+	Opacity    float64            `json:"opacity"`
+	Tint       color.NRGBA        `json:"-"`
+	OffsetX    float64            `json:"offsetx"`
+	OffsetY    float64            `json:"offsety"`
+	ParallaxX  float64            `json:"parallaxx"`
+	ParallaxY  float64            `json:"parallaxy"`
+	Layers     []TilemapLayerJSON `json:"layers"` // children of a group layer
+	// image layers only, the image path is relative to the map file
+	ImagePath string        `json:"image"`
+	RepeatX   bool          `json:"repeatx"`
+	RepeatY   bool          `json:"repeaty"`
+	Img       *ebiten.Image `json:"-"`
+}
+
+// newLayer returns a layer with tiled's defaults for the fields files
+// may leave out
+func newLayer() TilemapLayerJSON {
+	return TilemapLayerJSON{
+		Visible:   true,
+		Opacity:   1.0,
+		Tint:      color.NRGBA{255, 255, 255, 255},
+		ParallaxX: 1.0,
+		ParallaxY: 1.0,
+	}
+}
+
+type chunkJSON struct {
+	Data   json.RawMessage `json:"data"`
+	X      int             `json:"x"`
+	Y      int             `json:"y"`
+	Width  int             `json:"width"`
+	Height int             `json:"height"`
+}
+
+// UnmarshalJSON decodes the layer's tile data, which is either an array
+// of gids or a base64 string that may be compressed, and splits it into
+// clean gids and their flips. Infinite maps keep their data in chunks.
+func (t *TilemapLayerJSON) UnmarshalJSON(data []byte) error {
+	type layerJSON TilemapLayerJSON
+	aux := struct {
+		*layerJSON
+		Data        json.RawMessage `json:"data"`
+		Chunks      []chunkJSON     `json:"chunks"`
+		Encoding    string          `json:"encoding"`
+		Compression string          `json:"compression"`
+		TintColor   string          `json:"tintcolor"`
+	}{
+		layerJSON: (*layerJSON)(t),
+	}
+	*t = newLayer()
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if aux.TintColor != "" {
+		t.Tint, err = tiled.ParseColor(aux.TintColor)
+		if err != nil {
+			return fmt.Errorf("layer %q: %w", t.Name, err)
+		}
+	}
+
+	if len(aux.Data) > 0 {
+		aux.Chunks = append(aux.Chunks, chunkJSON{
+			Data:   aux.Data,
+			Width:  t.Width,
+			Height: t.Height,
+		})
+	}
+
+	for _, chunk := range aux.Chunks {
+		gids, err := decodeJSONGIDs(chunk.Data, aux.Encoding, aux.Compression)
+		if err != nil {
+			return fmt.Errorf("layer %q: %w", t.Name, err)
+		}
+		t.Chunks = append(t.Chunks, newChunk(chunk.X, chunk.Y, chunk.Width, chunk.Height, gids))
+	}
+	return nil
+}
+
+func decodeJSONGIDs(data json.RawMessage, encoding, compression string) ([]uint32, error) {
+	if encoding != "base64" {
+		var gids []uint32
+		err := json.Unmarshal(data, &gids)
+		return gids, err
+	}
+
+	var encoded string
+	err := json.Unmarshal(data, &encoded)
+	if err != nil {
+		return nil, err
+	}
+	return decodeGIDs(encoded, encoding, compression)
+}
+
+const (
+	TileLayer   = "tilelayer"
+	ObjectGroup = "objectgroup"
+	ImageLayer  = "imagelayer"
+	GroupLayer  = "group"
+)
+
+// flattenLayers replaces group layers with their children, folding the
+// group's visibility, opacity, tint, offset and parallax into each child
+func flattenLayers(layers []TilemapLayerJSON) []TilemapLayerJSON {
+	flat := make([]TilemapLayerJSON, 0, len(layers))
+	for _, layer := range layers {
+		if layer.Type != GroupLayer {
+			flat = append(flat, layer)
+			continue
+		}
+
+		for _, child := range flattenLayers(layer.Layers) {
+			child.Visible = child.Visible && layer.Visible
+			child.Opacity *= layer.Opacity
+			child.Tint = color.NRGBA{
+				R: uint8(uint16(child.Tint.R) * uint16(layer.Tint.R) / 255),
+				G: uint8(uint16(child.Tint.G) * uint16(layer.Tint.G) / 255),
+				B: uint8(uint16(child.Tint.B) * uint16(layer.Tint.B) / 255),
+				A: uint8(uint16(child.Tint.A) * uint16(layer.Tint.A) / 255),
+			}
+			child.OffsetX += layer.OffsetX
+			child.OffsetY += layer.OffsetY
+			child.ParallaxX *= layer.ParallaxX
+			child.ParallaxY *= layer.ParallaxY
+			flat = append(flat, child)
+		}
+	}
+	return flat
+}
+
+// TilesetRefJSON is a tileset entry of a map. It either points to an
+// external tileset file or holds the whole tileset embedded in the map.
+type TilesetRefJSON struct {
+	FirstGID int                  `json:"firstgid"`
+	Source   string               `json:"source"`
+	Embedded *tileset.TilesetJSON `json:"-"`
+}
+
+func (t *TilesetRefJSON) UnmarshalJSON(data []byte) error {
+	type tilesetRefJSON TilesetRefJSON
+	err := json.Unmarshal(data, (*tilesetRefJSON)(t))
+	if err != nil {
+		return err
+	}
+	if t.Source != "" {
+		return nil
+	}
+
+	t.Embedded = &tileset.TilesetJSON{}
+	return json.Unmarshal(data, t.Embedded)
 }
 
 type TilemapJSON struct {
-	Layers   []TilemapLayerJSON `json:"layers"`
-	Tilesets []map[string]any   `json:"tilesets"`
+	Layers     []TilemapLayerJSON `json:"layers"`
+	Tilesets   []TilesetRefJSON   `json:"tilesets"`
+	Width      int                `json:"width"`
+	Height     int                `json:"height"`
+	TileWidth  int                `json:"tilewidth"`
+	TileHeight int                `json:"tileheight"`
+	Infinite   bool               `json:"infinite"`
+	Properties tiled.Properties   `json:"properties"`
+	// file system and directory of the map file, which tileset paths
+	// are relative to
+	fsys fs.FS
+	dir  string
+}
+
+// TileSize returns the size of a map cell in pixels
+func (t *TilemapJSON) TileSize() (int, int) {
+	tileWidth, tileHeight := t.TileWidth, t.TileHeight
+	if tileWidth <= 0 {
+		tileWidth = constants.Tilesize
+	}
+	if tileHeight <= 0 {
+		tileHeight = constants.Tilesize
+	}
+	return tileWidth, tileHeight
+}
+
+// PixelBounds returns the area of the map in pixels
+func (t *TilemapJSON) PixelBounds() image.Rectangle {
+	tileWidth, tileHeight := t.TileSize()
+	bounds := t.Bounds()
+	return image.Rect(
+		bounds.Min.X*tileWidth,
+		bounds.Min.Y*tileHeight,
+		bounds.Max.X*tileWidth,
+		bounds.Max.Y*tileHeight,
+	)
 }
 
-func (t *TilemapJSON) GenTilesets() ([]tileset.Tileset, error) {
+// Bounds returns the area of the map in tiles. Infinite maps have no
+// fixed size, so their bounds are those of the chunks painted so far.
+func (t *TilemapJSON) Bounds() image.Rectangle {
+	if !t.Infinite {
+		return image.Rect(0, 0, t.Width, t.Height)
+	}
+	bounds := image.Rectangle{}
+	for _, layer := range t.Layers {
+		bounds = bounds.Union(layer.Bounds())
+	}
+	return bounds
+}
 
-	tilesets := make([]tileset.Tileset, 0)
+// TilesetFS is a file system that hands out loaded tilesets itself, such
+// as one that shares them between maps
+type TilesetFS interface {
+	fs.FS
+	Tileset(name string) (tileset.Tileset, error)
+}
+
+// loadTileset loads a tileset in fsys, through its Tileset method if it
+// has one
+func loadTileset(fsys fs.FS, name string) (tileset.Tileset, error) {
+	if tilesetFS, ok := fsys.(TilesetFS); ok {
+		return tilesetFS.Tileset(name)
+	}
+	return tileset.NewTileset(fsys, name)
+}
 
-	for _, tilesetData := range t.Tilesets {
-		tilesetPath := path.Join("assets/maps/", tilesetData["source"].(string))
-		tileset, err := tileset.NewTileset(tilesetPath, int(tilesetData["firstgid"].(float64)))
+func (t *TilemapJSON) GenTilesets() (Tilesets, error) {
+
+	tilesets := make(Tilesets, 0)
+
+	for _, tilesetRef := range t.Tilesets {
+		var ts tileset.Tileset
+		var err error
+		if tilesetRef.Embedded != nil {
+			ts, err = tileset.NewTilesetFromJSON(t.fsys, tilesetRef.Embedded, t.dir)
+		} else {
+			ts, err = loadTileset(t.fsys, t.tilesetPath(tilesetRef))
+		}
 		if err != nil {
 			return nil, err
 		}
 
-		tilesets = append(tilesets, tileset)
+		tilesets = append(tilesets, &MapTileset{
+			FirstGID: tilesetRef.FirstGID,
+			Tileset:  ts,
+		})
 	}
 
 	return tilesets, nil
 }
 
-func NewTilemapJSON(filepath string) (*TilemapJSON, error) {
-	contents, err := os.ReadFile(filepath)
+// TilesetPaths returns the paths of the map's external tilesets in the
+// file system it was loaded from
+func (t *TilemapJSON) TilesetPaths() []string {
+	paths := make([]string, 0, len(t.Tilesets))
+	for _, tilesetRef := range t.Tilesets {
+		if tilesetRef.Embedded == nil {
+			paths = append(paths, t.tilesetPath(tilesetRef))
+		}
+	}
+	return paths
+}
+
+func (t *TilemapJSON) tilesetPath(tilesetRef TilesetRefJSON) string {
+	return path.Join(t.dir, strings.ReplaceAll(tilesetRef.Source, "\\", "/"))
+}
+
+// Name returns the name of an external tileset, its file name without
+// the extension. Embedded tilesets have no name.
+func (t *TilesetRefJSON) Name() string {
+	if t.Source == "" {
+		return ""
+	}
+	base := path.Base(strings.ReplaceAll(t.Source, "\\", "/"))
+	return strings.TrimSuffix(base, path.Ext(base))
+}
+
+// FirstGID returns the first gid of the tileset with the given name
+func (t *TilemapJSON) FirstGID(name string) (int, bool) {
+	for _, tilesetRef := range t.Tilesets {
+		if tilesetRef.Name() == name {
+			return tilesetRef.FirstGID, true
+		}
+	}
+	return 0, false
+}
+
+// TilesetRef returns the tileset entry a gid belongs to
+func (t *TilemapJSON) TilesetRef(gid int) (*TilesetRefJSON, bool) {
+	var found *TilesetRefJSON
+	for index := range t.Tilesets {
+		tilesetRef := &t.Tilesets[index]
+		if tilesetRef.FirstGID <= gid && (found == nil || tilesetRef.FirstGID > found.FirstGID) {
+			found = tilesetRef
+		}
+	}
+	return found, found != nil
+}
+
+// LoadImages loads the images shown by image layers
+func (t *TilemapJSON) LoadImages() error {
+	for index := range t.Layers {
+		layer := &t.Layers[index]
+		if layer.Type != ImageLayer || layer.ImagePath == "" {
+			continue
+		}
+		imgPath := path.Join(t.dir, strings.ReplaceAll(layer.ImagePath, "\\", "/"))
+		img, err := tileset.LoadImage(t.fsys, imgPath)
+		if err != nil {
+			return err
+		}
+		layer.Img = img
+	}
+	return nil
+}
+
+// Validate checks that every tile in the map belongs to one of the
+// tilesets and that the tileset has an image for it
+func (t *TilemapJSON) Validate(tilesets Tilesets) error {
+	for _, layer := range t.Layers {
+		var err error
+		layer.ForEachTile(func(x, y, gid int, flip Flip) {
+			if _, _, tileErr := tilesets.Tile(gid); tileErr != nil && err == nil {
+				err = fmt.Errorf("layer %q at %d,%d: %w", layer.Name, x, y, tileErr)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Colliders builds collision rectangles from the painted tiles. Tiles
+// marked solid block their whole image, other tiles block the collision
+// shapes set up for them in the tileset.
+func (t *TilemapJSON) Colliders(tilesets Tilesets) []image.Rectangle {
+	colliders := make([]image.Rectangle, 0)
+	tileWidth, tileHeight := t.TileSize()
+	for _, layer := range t.Layers {
+		if layer.Type != TileLayer {
+			continue
+		}
+		layer.ForEachTile(func(x, y, gid int, flip Flip) {
+			ts, localId, err := tilesets.Resolve(gid)
+			if err != nil {
+				return
+			}
+
+			solid := ts.Properties(localId).Bool("solid", false)
+			shapes := ts.Collision(localId)
+			if !solid && len(shapes) == 0 {
+				return
+			}
+
+			//tiles are anchored to the bottom left of their cell
+			img, err := ts.Img(localId)
+			if err != nil {
+				return
+			}
+			imgW, imgH := img.Bounds().Dx(), img.Bounds().Dy()
+			_, h := flip.Size(imgW, imgH)
+			origin := image.Pt(
+				x*tileWidth,
+				(y+1)*tileHeight-h,
+			).Add(ts.TileOffset())
+
+			if solid {
+				colliders = append(colliders, flip.Rect(image.Rect(0, 0, imgW, imgH), imgW, imgH).Add(origin))
+				return
+			}
+			for _, shape := range shapes {
+				colliders = append(colliders, flip.Rect(shape.Bounds(), imgW, imgH).Add(origin))
+			}
+		})
+	}
+	return colliders
+}
+
+// LayerIndex returns the index of the first layer with the given name, or
+// -1 if there is none
+func (t *TilemapJSON) LayerIndex(name string) int {
+	for index, layer := range t.Layers {
+		if layer.Name == name {
+			return index
+		}
+	}
+	return -1
+}
+
+// Objects returns the objects of every object group in the map
+func (t *TilemapJSON) Objects() []tiled.Object {
+	objects := make([]tiled.Object, 0)
+	for _, layer := range t.Layers {
+		if layer.Type == ObjectGroup {
+			objects = append(objects, layer.Objects...)
+		}
+	}
+	return objects
+}
+
+// NewTilemap loads a map from fsys in either the json or the tmx format,
+// going by the file extension and falling back to the file contents
+func NewTilemap(fsys fs.FS, filepath string) (*TilemapJSON, error) {
+	switch strings.ToLower(path.Ext(filepath)) {
+	case ".json", ".tmj":
+		return NewTilemapJSON(fsys, filepath)
+	case ".tmx":
+		return NewTilemapTMX(fsys, filepath)
+	}
+
+	contents, err := fs.ReadFile(fsys, filepath)
+	if err != nil {
+		return nil, err
+	}
+	if tiled.IsXML(contents) {
+		return parseTilemapTMX(fsys, contents, path.Dir(filepath))
+	}
+	return parseTilemapJSON(fsys, contents, path.Dir(filepath))
+}
+
+func NewTilemapJSON(fsys fs.FS, filepath string) (*TilemapJSON, error) {
+	contents, err := fs.ReadFile(fsys, filepath)
 	if err != nil {
 		return nil, err
 	}
 
+	return parseTilemapJSON(fsys, contents, path.Dir(filepath))
+}
+
+func parseTilemapJSON(fsys fs.FS, contents []byte, dir string) (*TilemapJSON, error) {
 	var tilemapJSON TilemapJSON
-	err = json.Unmarshal(contents, &tilemapJSON)
+	err := json.Unmarshal(contents, &tilemapJSON)
 	if err != nil {
 		return nil, err
 	}
+	tilemapJSON.fsys = fsys
+	tilemapJSON.dir = dir
+	tilemapJSON.Layers = flattenLayers(tilemapJSON.Layers)
 
 	return &tilemapJSON, nil
 }
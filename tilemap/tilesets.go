@@ -0,0 +1,68 @@
+package tilemap
+
+import (
+	"EndlessJourney/tileset"
+	"fmt"
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// MapTileset is a tileset as referenced by a map. Its tiles occupy the
+// global ids FirstGID through FirstGID+TileCount()-1.
+type MapTileset struct {
+	FirstGID int
+	tileset.Tileset
+}
+
+// Tilesets are the tilesets of a map, ordered by FirstGID
+type Tilesets []*MapTileset
+
+// Resolve finds the tileset a global tile id belongs to and returns
+// the tile's local id within that tileset
+func (t Tilesets) Resolve(gid int) (tileset.Tileset, int, error) {
+	// tiled orders tilesets by firstgid, so the owner is the last
+	// tileset starting at or before the gid
+	for i := len(t) - 1; i >= 0; i-- {
+		if t[i].FirstGID > gid {
+			continue
+		}
+		id := gid - t[i].FirstGID
+		if id >= t[i].TileCount() {
+			break
+		}
+		return t[i].Tileset, id, nil
+	}
+	return nil, 0, fmt.Errorf("tilemap: no tileset contains gid %d", gid)
+}
+
+// Img returns the image of the tile with the given global id, showing
+// the current frame if the tile is animated
+func (t Tilesets) Img(gid int) (*ebiten.Image, error) {
+	img, _, err := t.Tile(gid)
+	return img, err
+}
+
+// Tile returns the current image of the tile with the given global id
+// along with the pixel offset its tileset draws tiles at
+func (t Tilesets) Tile(gid int) (*ebiten.Image, image.Point, error) {
+	ts, id, err := t.Resolve(gid)
+	if err != nil {
+		return nil, image.Point{}, err
+	}
+	if animation := ts.Animation(id); animation != nil {
+		id = animation.Frame()
+	}
+	img, err := ts.Img(id)
+	if err != nil {
+		return nil, image.Point{}, fmt.Errorf("tilemap: gid %d: %w", gid, err)
+	}
+	return img, ts.TileOffset(), nil
+}
+
+// Update advances the tile animations of every tileset
+func (t Tilesets) Update() {
+	for _, ts := range t {
+		ts.Update()
+	}
+}
@@ -0,0 +1,62 @@
+package tilemap
+
+import (
+	"EndlessJourney/tileset"
+	"testing"
+)
+
+// countTileset is a tileset that only knows how many tiles it has
+type countTileset struct {
+	tileset.Tileset
+	count int
+}
+
+func (c *countTileset) TileCount() int {
+	return c.count
+}
+
+func TestTilesetsResolve(t *testing.T) {
+	first := &countTileset{count: 4}
+	second := &countTileset{count: 10}
+	// the third tileset leaves a gap after the second one, as happens
+	// when a tileset loses tiles after the map was saved
+	third := &countTileset{count: 1}
+	tilesets := Tilesets{
+		{FirstGID: 1, Tileset: first},
+		{FirstGID: 5, Tileset: second},
+		{FirstGID: 20, Tileset: third},
+	}
+
+	tests := []struct {
+		gid     int
+		tileset tileset.Tileset
+		id      int
+		wantErr bool
+	}{
+		{gid: 0, wantErr: true},
+		{gid: 1, tileset: first, id: 0},
+		{gid: 4, tileset: first, id: 3},
+		{gid: 5, tileset: second, id: 0},
+		{gid: 14, tileset: second, id: 9},
+		{gid: 15, wantErr: true},
+		{gid: 19, wantErr: true},
+		{gid: 20, tileset: third, id: 0},
+		{gid: 21, wantErr: true},
+	}
+	for _, test := range tests {
+		ts, id, err := tilesets.Resolve(test.gid)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("Resolve(%d) found tile %d, want an error", test.gid, id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Resolve(%d): %v", test.gid, err)
+			continue
+		}
+		if ts != test.tileset || id != test.id {
+			t.Errorf("Resolve(%d) = %p, %d, want %p, %d", test.gid, ts, id, test.tileset, test.id)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package tilemap
+
+import (
+	"EndlessJourney/tiled"
+	"EndlessJourney/tileset"
+	"encoding/xml"
+	"io/fs"
+	"path"
+)
+
+type tmxTile struct {
+	Gid uint32 `xml:"gid,attr"`
+}
+
+type tmxChunk struct {
+	X      int    `xml:"x,attr"`
+	Y      int    `xml:"y,attr"`
+	Width  int    `xml:"width,attr"`
+	Height int    `xml:"height,attr"`
+	Text   string `xml:",chardata"`
+	// the deprecated xml encoding lists every tile as an element
+	Tiles []tmxTile `xml:"tile"`
+}
+
+type tmxData struct {
+	Encoding    string     `xml:"encoding,attr"`
+	Compression string     `xml:"compression,attr"`
+	Text        string     `xml:",chardata"`
+	Tiles       []tmxTile  `xml:"tile"`
+	Chunks      []tmxChunk `xml:"chunk"`
+}
+
+// gids decodes tile data whichever encoding it was saved with
+func (t *tmxData) gids(text string, tiles []tmxTile) ([]uint32, error) {
+	if t.Encoding == "" {
+		gids := make([]uint32, len(tiles))
+		for index, tile := range tiles {
+			gids[index] = tile.Gid
+		}
+		return gids, nil
+	}
+	return decodeGIDs(text, t.Encoding, t.Compression)
+}
+
+// chunks decodes the layer data of a finite layer into a single chunk,
+// or each chunk of an infinite one
+func (t *tmxData) chunks(width, height int) ([]*Chunk, error) {
+	if len(t.Chunks) == 0 {
+		gids, err := t.gids(t.Text, t.Tiles)
+		if err != nil {
+			return nil, err
+		}
+		return []*Chunk{newChunk(0, 0, width, height, gids)}, nil
+	}
+
+	chunks := make([]*Chunk, 0, len(t.Chunks))
+	for _, chunk := range t.Chunks {
+		gids, err := t.gids(chunk.Text, chunk.Tiles)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, newChunk(chunk.X, chunk.Y, chunk.Width, chunk.Height, gids))
+	}
+	return chunks, nil
+}
+
+type tmxImage struct {
+	Source string `xml:"source,attr"`
+}
+
+// tmxLayer holds any child of the map or of a group. The kinds of layer
+// are told apart by XMLName so that their order is kept.
+type tmxLayer struct {
+	XMLName    xml.Name
+	Name       string           `xml:"name,attr"`
+	Width      int              `xml:"width,attr"`
+	Height     int              `xml:"height,attr"`
+	Visible    *int             `xml:"visible,attr"`
+	Opacity    *float64         `xml:"opacity,attr"`
+	TintColor  string           `xml:"tintcolor,attr"`
+	OffsetX    float64          `xml:"offsetx,attr"`
+	OffsetY    float64          `xml:"offsety,attr"`
+	ParallaxX  *float64         `xml:"parallaxx,attr"`
+	ParallaxY  *float64         `xml:"parallaxy,attr"`
+	RepeatX    int              `xml:"repeatx,attr"`
+	RepeatY    int              `xml:"repeaty,attr"`
+	Properties tiled.Properties `xml:"properties"`
+	Data       tmxData          `xml:"data"`
+	Objects    []tiled.Object   `xml:"object"`
+	Image      *tmxImage        `xml:"image"`
+	Layers     []tmxLayer       `xml:",any"`
+}
+
+// layers converts tmx layers into the json model, skipping elements that
+// are not layers
+func tmxLayers(layersTMX []tmxLayer) ([]TilemapLayerJSON, error) {
+	layers := make([]TilemapLayerJSON, 0)
+	for _, layerTMX := range layersTMX {
+		layer := newLayer()
+		layer.Name = layerTMX.Name
+		layer.Width = layerTMX.Width
+		layer.Height = layerTMX.Height
+		layer.Properties = layerTMX.Properties
+		layer.OffsetX = layerTMX.OffsetX
+		layer.OffsetY = layerTMX.OffsetY
+		if layerTMX.Visible != nil {
+			layer.Visible = *layerTMX.Visible != 0
+		}
+		if layerTMX.Opacity != nil {
+			layer.Opacity = *layerTMX.Opacity
+		}
+		if layerTMX.ParallaxX != nil {
+			layer.ParallaxX = *layerTMX.ParallaxX
+		}
+		if layerTMX.ParallaxY != nil {
+			layer.ParallaxY = *layerTMX.ParallaxY
+		}
+		if layerTMX.TintColor != "" {
+			tint, err := tiled.ParseColor(layerTMX.TintColor)
+			if err != nil {
+				return nil, err
+			}
+			layer.Tint = tint
+		}
+
+		switch layerTMX.XMLName.Local {
+		case "layer":
+			layer.Type = TileLayer
+			chunks, err := layerTMX.Data.chunks(layer.Width, layer.Height)
+			if err != nil {
+				return nil, err
+			}
+			layer.Chunks = chunks
+		case "objectgroup":
+			layer.Type = ObjectGroup
+			layer.Objects = layerTMX.Objects
+		case "imagelayer":
+			layer.Type = ImageLayer
+			layer.RepeatX = layerTMX.RepeatX != 0
+			layer.RepeatY = layerTMX.RepeatY != 0
+			if layerTMX.Image != nil {
+				layer.ImagePath = layerTMX.Image.Source
+			}
+		case "group":
+			layer.Type = GroupLayer
+			children, err := tmxLayers(layerTMX.Layers)
+			if err != nil {
+				return nil, err
+			}
+			layer.Layers = children
+		default:
+			continue
+		}
+
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+// tmxTileset is either a reference to a tileset file or, without a
+// source, a whole tileset embedded in the map
+type tmxTileset struct {
+	FirstGID int    `xml:"firstgid,attr"`
+	Source   string `xml:"source,attr"`
+	tileset.TilesetXML
+}
+
+type tmxMap struct {
+	XMLName    xml.Name         `xml:"map"`
+	Width      int              `xml:"width,attr"`
+	Height     int              `xml:"height,attr"`
+	TileWidth  int              `xml:"tilewidth,attr"`
+	TileHeight int              `xml:"tileheight,attr"`
+	Infinite   int              `xml:"infinite,attr"`
+	Properties tiled.Properties `xml:"properties"`
+	Tilesets   []tmxTileset     `xml:"tileset"`
+	Layers     []tmxLayer       `xml:",any"`
+}
+
+func NewTilemapTMX(fsys fs.FS, filepath string) (*TilemapJSON, error) {
+	contents, err := fs.ReadFile(fsys, filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTilemapTMX(fsys, contents, path.Dir(filepath))
+}
+
+// parseTilemapTMX builds the same map model the json loader produces
+func parseTilemapTMX(fsys fs.FS, contents []byte, dir string) (*TilemapJSON, error) {
+	var tmx tmxMap
+	err := xml.Unmarshal(contents, &tmx)
+	if err != nil {
+		return nil, err
+	}
+
+	tilemapJSON := TilemapJSON{
+		Layers:     make([]TilemapLayerJSON, 0),
+		Tilesets:   make([]TilesetRefJSON, 0),
+		Width:      tmx.Width,
+		Height:     tmx.Height,
+		TileWidth:  tmx.TileWidth,
+		TileHeight: tmx.TileHeight,
+		Infinite:   tmx.Infinite != 0,
+		Properties: tmx.Properties,
+		fsys:       fsys,
+		dir:        dir,
+	}
+
+	for _, tilesetTMX := range tmx.Tilesets {
+		tilesetRef := TilesetRefJSON{
+			FirstGID: tilesetTMX.FirstGID,
+			Source:   tilesetTMX.Source,
+		}
+		if tilesetTMX.Source == "" {
+			tilesetRef.Embedded = tilesetTMX.TilesetXML.JSON()
+		}
+		tilemapJSON.Tilesets = append(tilemapJSON.Tilesets, tilesetRef)
+	}
+
+	layers, err := tmxLayers(tmx.Layers)
+	if err != nil {
+		return nil, err
+	}
+	tilemapJSON.Layers = flattenLayers(layers)
+
+	return &tilemapJSON, nil
+}
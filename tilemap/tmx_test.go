@@ -0,0 +1,140 @@
+package tilemap
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+// the same 3x2 layer in every encoding, with a flipped and an empty tile
+var tmxGIDs = []uint32{1, 2, 0x80000000 | 3, 0, 4, 1}
+
+const tmxFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" width="3" height="2" tilewidth="16" tileheight="16" infinite="0">
+ <properties>
+  <property name="music" value="town.ogg"/>
+ </properties>
+ <tileset firstgid="1" name="embedded" tilewidth="16" tileheight="16" tilecount="4" columns="2">
+  <image source="tiles.png" width="32" height="32"/>
+  <tile id="1">
+   <properties>
+    <property name="solid" type="bool" value="true"/>
+   </properties>
+   <objectgroup>
+    <object id="1" x="0" y="8" width="16" height="8"/>
+   </objectgroup>
+  </tile>
+  <tile id="3">
+   <animation>
+    <frame tileid="3" duration="100"/>
+    <frame tileid="2" duration="150"/>
+   </animation>
+  </tile>
+ </tileset>
+ <tileset firstgid="5" source="other.tsx"/>
+ <layer id="1" name="csv" width="3" height="2">
+  <data encoding="csv">
+%s
+</data>
+ </layer>
+ <layer id="2" name="base64" width="3" height="2">
+  <data encoding="base64">
+   %s
+  </data>
+ </layer>
+ <layer id="3" name="zlib" width="3" height="2">
+  <data encoding="base64" compression="zlib">%s</data>
+ </layer>
+ <layer id="4" name="gzip" width="3" height="2">
+  <data encoding="base64" compression="gzip">%s</data>
+ </layer>
+ <objectgroup id="5" name="objects">
+  <object id="1" name="door" type="portal" x="16" y="0" width="16" height="16">
+   <properties>
+    <property name="map" value="house.tmx"/>
+   </properties>
+  </object>
+  <object id="2" type="spawn" x="8" y="24">
+   <point/>
+  </object>
+ </objectgroup>
+</map>
+`
+
+// jsonFixture is the map of tmxFixture as tiled saves it in json
+const jsonFixture = `{
+ "width": 3, "height": 2, "tilewidth": 16, "tileheight": 16, "infinite": false,
+ "properties": [{"name": "music", "type": "string", "value": "town.ogg"}],
+ "tilesets": [
+  {"firstgid": 1, "name": "embedded", "tilewidth": 16, "tileheight": 16, "tilecount": 4, "columns": 2,
+   "image": "tiles.png", "imagewidth": 32, "imageheight": 32,
+   "tiles": [
+    {"id": 1,
+     "properties": [{"name": "solid", "type": "bool", "value": true}],
+     "objectgroup": {"objects": [{"id": 1, "x": 0, "y": 8, "width": 16, "height": 8}]}},
+    {"id": 3, "animation": [{"tileid": 3, "duration": 100}, {"tileid": 2, "duration": 150}]}
+   ]},
+  {"firstgid": 5, "source": "other.tsx"}
+ ],
+ "layers": [
+  {"name": "csv", "type": "tilelayer", "width": 3, "height": 2, "visible": true, "opacity": 1,
+   "data": [1, 2, 2147483651, 0, 4, 1]},
+  {"name": "base64", "type": "tilelayer", "width": 3, "height": 2, "visible": true, "opacity": 1,
+   "data": [1, 2, 2147483651, 0, 4, 1]},
+  {"name": "zlib", "type": "tilelayer", "width": 3, "height": 2, "visible": true, "opacity": 1,
+   "data": [1, 2, 2147483651, 0, 4, 1]},
+  {"name": "gzip", "type": "tilelayer", "width": 3, "height": 2, "visible": true, "opacity": 1,
+   "data": [1, 2, 2147483651, 0, 4, 1]},
+  {"name": "objects", "type": "objectgroup", "visible": true, "opacity": 1, "objects": [
+   {"id": 1, "name": "door", "type": "portal", "x": 16, "y": 0, "width": 16, "height": 16, "visible": true,
+    "properties": [{"name": "map", "type": "string", "value": "house.tmx"}]},
+   {"id": 2, "name": "", "type": "spawn", "x": 8, "y": 24, "width": 0, "height": 0, "visible": true, "point": true}
+  ]}
+ ]
+}`
+
+func TestTilemapTMXMatchesJSON(t *testing.T) {
+	tmx := fmt.Sprintf(tmxFixture,
+		"1,2,2147483651,\n0,4,1",
+		encodeGIDs(t, tmxGIDs, ""),
+		encodeGIDs(t, tmxGIDs, "zlib"),
+		encodeGIDs(t, tmxGIDs, "gzip"),
+	)
+	fsys := fstest.MapFS{
+		"maps/town.tmx":  {Data: []byte(tmx)},
+		"maps/town.json": {Data: []byte(jsonFixture)},
+	}
+
+	fromTMX, err := NewTilemap(fsys, "maps/town.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON, err := NewTilemap(fsys, "maps/town.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(fromTMX.Properties, fromJSON.Properties) {
+		t.Errorf("tmx properties %v, json %v", fromTMX.Properties, fromJSON.Properties)
+	}
+	if !reflect.DeepEqual(fromTMX.Tilesets, fromJSON.Tilesets) {
+		t.Errorf("tmx tilesets\n%+v\njson\n%+v", fromTMX.Tilesets, fromJSON.Tilesets)
+	}
+	if len(fromTMX.Layers) != len(fromJSON.Layers) {
+		t.Fatalf("tmx has %d layers, json %d", len(fromTMX.Layers), len(fromJSON.Layers))
+	}
+	for index, layer := range fromTMX.Layers {
+		if !reflect.DeepEqual(layer, fromJSON.Layers[index]) {
+			t.Errorf("layer %q differs\ntmx  %+v\njson %+v", layer.Name, layer, fromJSON.Layers[index])
+		}
+	}
+
+	// every encoding decodes to the same tiles
+	want := newChunk(0, 0, 3, 2, tmxGIDs)
+	for _, layer := range fromTMX.Layers[:4] {
+		if len(layer.Chunks) != 1 || !reflect.DeepEqual(layer.Chunks[0], want) {
+			t.Errorf("layer %q chunks %+v, want %+v", layer.Name, layer.Chunks, want)
+		}
+	}
+}
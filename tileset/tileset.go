@@ -1,46 +1,138 @@
 package tileset
 
 import (
+	"EndlessJourney/animations"
 	"EndlessJourney/constants"
+	"EndlessJourney/tiled"
 	"encoding/json"
+	"fmt"
 	"image"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"path"
 	"strings"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 )
 
+// Tileset hands out tile images by their local id, that is the
+// id relative to the tileset rather than the map's global id
 type Tileset interface {
-	Img(id int) *ebiten.Image
+	Img(id int) (*ebiten.Image, error)
+	// one past the highest local id, image collections may have gaps
+	TileCount() int
+	// custom properties of a tile, such as solid or footstep
+	Properties(id int) tiled.Properties
+	// collision shapes of a tile, relative to the tile's top left corner
+	Collision(id int) []tiled.Object
+	// animation of a tile, nil if the tile is static
+	Animation(id int) *animations.FrameAnimation
+	// advances the tile animations by one tick
+	Update()
+	// pixel offset every tile of the tileset is drawn at
+	TileOffset() image.Point
+	// size of the largest tile in pixels
+	TileSize() (int, int)
+	// ways placed tiles may be flipped and rotated, nil if the tileset
+	// doesn't limit them
+	Transformations() *TransformationsJSON
 }
 
-type UniformTilesetJSON struct {
-	Path string `json:"image"`
+// TransformationsJSON are the flips and rotations tiled allows for the
+// tiles of a tileset
+type TransformationsJSON struct {
+	HFlip  bool `json:"hflip" xml:"hflip,attr"`
+	VFlip  bool `json:"vflip" xml:"vflip,attr"`
+	Rotate bool `json:"rotate" xml:"rotate,attr"`
+}
+
+type TileOffsetJSON struct {
+	X int `json:"x" xml:"x,attr"`
+	Y int `json:"y" xml:"y,attr"`
+}
+
+// TilesetJSON is the tileset data shared by every kind of tileset
+type TilesetJSON struct {
+	Path        string          `json:"image"`
+	ImageWidth  int             `json:"imagewidth"`
+	ImageHeight int             `json:"imageheight"`
+	TileCount   int             `json:"tilecount"`
+	Columns     int             `json:"columns"`
+	TileWidth   int             `json:"tilewidth"`
+	TileHeight  int             `json:"tileheight"`
+	Margin      int             `json:"margin"`
+	Spacing     int             `json:"spacing"`
+	TileOffset  *TileOffsetJSON `json:"tileoffset"`
+	Tiles       []*TileJSON     `json:"tiles"`
+	// allowed flips and rotations, nil if unlimited
+	Transformations *TransformationsJSON `json:"transformations"`
+}
+
+// IsImageCollection reports whether the tileset is a collection of
+// separate images rather than one image cut into a grid
+func (t *TilesetJSON) IsImageCollection() bool {
+	if t.Path != "" {
+		return false
+	}
+	if t.Columns == 0 {
+		return true
+	}
+	for _, tileJSON := range t.Tiles {
+		if tileJSON.Path != "" {
+			return true
+		}
+	}
+	return false
 }
 
 type UniformTileset struct {
-	img *ebiten.Image
-	gid int
+	tileData
+	img        *ebiten.Image
+	tileCount  int
+	columns    int
+	tileWidth  int
+	tileHeight int
+	margin     int
+	spacing    int
 }
 
-func (u *UniformTileset) Img(id int) *ebiten.Image {
+func (u *UniformTileset) Img(id int) (*ebiten.Image, error) {
+
+	if id < 0 || id >= u.tileCount {
+		return nil, fmt.Errorf("tileset: no tile with id %d", id)
+	}
 
-	id -= u.gid
 	//get the position on the image where the tile id is
-	srcX := id % 22
-	srcY := id / 22
+	srcX := id % u.columns
+	srcY := id / u.columns
 
-	//convert the src tile position to pixel src position
-	srcX *= constants.Tilesize
-	srcY *= constants.Tilesize
+	//convert the src tile position to pixel src position, skipping the
+	//margin around the image and the spacing between tiles
+	srcX = u.margin + srcX*(u.tileWidth+u.spacing)
+	srcY = u.margin + srcY*(u.tileHeight+u.spacing)
 
 	return u.img.SubImage(
 		image.Rect(
-			srcX, srcY, srcX+constants.Tilesize, srcY+constants.Tilesize,
+			srcX, srcY, srcX+u.tileWidth, srcY+u.tileHeight,
 		),
-	).(*ebiten.Image)
+	).(*ebiten.Image), nil
+}
+
+func (u *UniformTileset) TileCount() int {
+	return u.tileCount
+}
+
+func (u *UniformTileset) TileSize() (int, int) {
+	return u.tileWidth, u.tileHeight
+}
+
+type ObjectGroupJSON struct {
+	Objects []tiled.Object `json:"objects" xml:"object"`
+}
+
+type FrameJSON struct {
+	TileId   int `json:"tileid" xml:"tileid,attr"`
+	Duration int `json:"duration" xml:"duration,attr"` // in milliseconds
 }
 
 type TileJSON struct {
@@ -48,82 +140,235 @@ type TileJSON struct {
 	Path   string `json:"image"`
 	Width  int    `json:"imagewidth"`
 	Height int    `json:"imageheight"`
+	// part of the image used by the tile, the whole image if unset
+	X           int              `json:"x"`
+	Y           int              `json:"y"`
+	SubWidth    int              `json:"width"`
+	SubHeight   int              `json:"height"`
+	Properties  tiled.Properties `json:"properties"`
+	ObjectGroup *ObjectGroupJSON `json:"objectgroup"`
+	Animation   []FrameJSON      `json:"animation"`
+}
+
+// tileData holds the per tile data both kinds of tileset can carry
+type tileData struct {
+	tiles           map[int]*TileJSON
+	animations      map[int]*animations.FrameAnimation
+	offset          image.Point
+	transformations *TransformationsJSON
+}
+
+func newTileData(tilesetJSON *TilesetJSON) tileData {
+	t := tileData{
+		tiles:           make(map[int]*TileJSON),
+		animations:      make(map[int]*animations.FrameAnimation),
+		transformations: tilesetJSON.Transformations,
+	}
+	if tilesetJSON.TileOffset != nil {
+		t.offset = image.Pt(tilesetJSON.TileOffset.X, tilesetJSON.TileOffset.Y)
+	}
+	for _, tileJSON := range tilesetJSON.Tiles {
+		t.tiles[tileJSON.Id] = tileJSON
+
+		if len(tileJSON.Animation) == 0 {
+			continue
+		}
+		frames := make([]int, len(tileJSON.Animation))
+		durations := make([]float32, len(tileJSON.Animation))
+		for index, frame := range tileJSON.Animation {
+			frames[index] = frame.TileId
+			durations[index] = float32(frame.Duration) * ebiten.DefaultTPS / 1000.0
+		}
+		t.animations[tileJSON.Id] = animations.NewFrameAnimation(frames, durations)
+	}
+	return t
+}
+
+func (t *tileData) Animation(id int) *animations.FrameAnimation {
+	return t.animations[id]
 }
 
-type DynTilesetJSON struct {
-	Tiles []*TileJSON `json:"tiles"`
+func (t *tileData) TileOffset() image.Point {
+	return t.offset
+}
+
+func (t *tileData) Transformations() *TransformationsJSON {
+	return t.transformations
+}
+
+func (t *tileData) Update() {
+	for _, animation := range t.animations {
+		animation.Update()
+	}
+}
+
+func (t *tileData) Properties(id int) tiled.Properties {
+	if tileJSON, ok := t.tiles[id]; ok {
+		return tileJSON.Properties
+	}
+	return nil
+}
+
+func (t *tileData) Collision(id int) []tiled.Object {
+	if tileJSON, ok := t.tiles[id]; ok && tileJSON.ObjectGroup != nil {
+		return tileJSON.ObjectGroup.Objects
+	}
+	return nil
 }
 
 type DynTileset struct {
-	imgs []*ebiten.Image
-	gid  int
+	tileData
+	imgs       map[int]*ebiten.Image
+	tileCount  int
+	tileWidth  int
+	tileHeight int
+}
+
+func (d *DynTileset) Img(id int) (*ebiten.Image, error) {
+	img, ok := d.imgs[id]
+	if !ok {
+		return nil, fmt.Errorf("tileset: no tile with id %d", id)
+	}
+	return img, nil
+}
+
+func (d *DynTileset) TileCount() int {
+	return d.tileCount
 }
 
-func (d *DynTileset) Img(id int) *ebiten.Image {
-	id -= d.gid
-	return d.imgs[id]
+func (d *DynTileset) TileSize() (int, int) {
+	return d.tileWidth, d.tileHeight
 }
 
-func NewTileset(path string, gid int) (Tileset, error) {
+// NewTileset loads a tileset from fsys in either the json or the tsx
+// format, going by the file extension and falling back to the contents
+func NewTileset(fsys fs.FS, filepath string) (Tileset, error) {
 
-	contents, err := os.ReadFile(path)
+	contents, err := fs.ReadFile(fsys, filepath)
 	if err != nil {
 		return nil, err
 	}
 
-	if strings.Contains(path, "buildings") {
-		//return dyn tileset
-		var dynTilesetJSON DynTilesetJSON
-		err = json.Unmarshal(contents, &dynTilesetJSON)
-		if err != nil {
-			return nil, err
-		}
+	var tilesetJSON *TilesetJSON
+	ext := strings.ToLower(path.Ext(filepath))
+	if ext == ".tsx" || (ext != ".json" && ext != ".tsj" && tiled.IsXML(contents)) {
+		tilesetJSON, err = parseTSX(contents)
+	} else {
+		tilesetJSON = &TilesetJSON{}
+		err = json.Unmarshal(contents, tilesetJSON)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		dynTileset := DynTileset{}
-		dynTileset.gid = gid
-		dynTileset.imgs = make([]*ebiten.Image, 0)
+	return NewTilesetFromJSON(fsys, tilesetJSON, path.Dir(filepath))
+}
 
-		for _, tileJSON := range dynTilesetJSON.Tiles {
+// NewTilesetFromJSON builds a tileset from already parsed data, such as a
+// tileset embedded in a map. Image paths are relative to dir in fsys.
+func NewTilesetFromJSON(fsys fs.FS, tilesetJSON *TilesetJSON, dir string) (Tileset, error) {
+
+	if tilesetJSON.IsImageCollection() {
+		//return dyn tileset
+		dynTileset := DynTileset{}
+		dynTileset.tileData = newTileData(tilesetJSON)
+		dynTileset.imgs = make(map[int]*ebiten.Image)
 
-			tileJSONPath := tileJSON.Path
-			tileJSONPath = filepath.Clean(tileJSONPath)
-			tileJSONPath = strings.ReplaceAll(tileJSONPath, "\\", "/")
-			tileJSONPath = strings.TrimPrefix(tileJSONPath, "../")
-			tileJSONPath = strings.TrimPrefix(tileJSONPath, "../")
-			tileJSONPath = filepath.Join("assets/", tileJSONPath)
+		for _, tileJSON := range tilesetJSON.Tiles {
+			if tileJSON.Path == "" {
+				continue
+			}
 
-			img, _, err := ebitenutil.NewImageFromFile(tileJSONPath)
+			img, err := LoadImage(fsys, imagePath(dir, tileJSON.Path))
 			if err != nil {
 				return nil, err
 			}
 
-			dynTileset.imgs = append(dynTileset.imgs, img)
+			//tiles can use just a part of their image
+			if tileJSON.SubWidth > 0 && tileJSON.SubHeight > 0 {
+				img = img.SubImage(image.Rect(
+					tileJSON.X,
+					tileJSON.Y,
+					tileJSON.X+tileJSON.SubWidth,
+					tileJSON.Y+tileJSON.SubHeight,
+				)).(*ebiten.Image)
+			}
+
+			dynTileset.imgs[tileJSON.Id] = img
+			dynTileset.tileCount = max(dynTileset.tileCount, tileJSON.Id+1)
+			dynTileset.tileWidth = max(dynTileset.tileWidth, img.Bounds().Dx())
+			dynTileset.tileHeight = max(dynTileset.tileHeight, img.Bounds().Dy())
 		}
 		return &dynTileset, nil
 	}
 
 	// return uniform tileset
-	var uniformTilesetJSON UniformTilesetJSON
-	err = json.Unmarshal(contents, &uniformTilesetJSON)
-	if err != nil {
-		return nil, err
-	}
-
 	uniformTileset := UniformTileset{}
+	uniformTileset.tileData = newTileData(tilesetJSON)
 
-	tileJSONPath := uniformTilesetJSON.Path
-	tileJSONPath = filepath.Clean(tileJSONPath)
-	tileJSONPath = strings.ReplaceAll(tileJSONPath, "\\", "/")
-	tileJSONPath = strings.TrimPrefix(tileJSONPath, "../")
-	tileJSONPath = strings.TrimPrefix(tileJSONPath, "../")
-	tileJSONPath = filepath.Join("assets/", tileJSONPath)
-
-	img, _, err := ebitenutil.NewImageFromFile(tileJSONPath)
+	img, err := LoadImage(fsys, imagePath(dir, tilesetJSON.Path))
 	if err != nil {
 		return nil, err
 	}
 	uniformTileset.img = img
-	uniformTileset.gid = gid
+	uniformTileset.tileCount = tilesetJSON.TileCount
+	uniformTileset.tileWidth = tilesetJSON.TileWidth
+	uniformTileset.tileHeight = tilesetJSON.TileHeight
+	uniformTileset.margin = tilesetJSON.Margin
+	uniformTileset.spacing = tilesetJSON.Spacing
+	uniformTileset.columns = tilesetJSON.Columns
+
+	if uniformTileset.tileWidth <= 0 {
+		uniformTileset.tileWidth = constants.Tilesize
+	}
+	if uniformTileset.tileHeight <= 0 {
+		uniformTileset.tileHeight = constants.Tilesize
+	}
+	//older and hand written files leave out the column and tile counts,
+	//work them out from the image
+	imageWidth, imageHeight := tilesetJSON.ImageWidth, tilesetJSON.ImageHeight
+	if imageWidth <= 0 || imageHeight <= 0 {
+		imageWidth, imageHeight = img.Bounds().Dx(), img.Bounds().Dy()
+	}
+	if uniformTileset.columns <= 0 {
+		uniformTileset.columns = gridCells(imageWidth, uniformTileset.tileWidth, uniformTileset.margin, uniformTileset.spacing)
+	}
+	if uniformTileset.tileCount <= 0 {
+		rows := gridCells(imageHeight, uniformTileset.tileHeight, uniformTileset.margin, uniformTileset.spacing)
+		uniformTileset.tileCount = uniformTileset.columns * rows
+	}
 
 	return &uniformTileset, nil
 }
+
+// gridCells returns how many tiles of the given size fit along a side of
+// a tileset image, at least one
+func gridCells(imageSize, tileSize, margin, spacing int) int {
+	return max((imageSize-2*margin+spacing)/(tileSize+spacing), 1)
+}
+
+// ImageFS is a file system that hands out decoded images itself, such as
+// one that shares them between everything that loads them
+type ImageFS interface {
+	fs.FS
+	Image(name string) (*ebiten.Image, error)
+}
+
+// LoadImage decodes an image in fsys, through its Image method if it has
+// one
+func LoadImage(fsys fs.FS, name string) (*ebiten.Image, error) {
+	if imageFS, ok := fsys.(ImageFS); ok {
+		return imageFS.Image(name)
+	}
+	img, _, err := ebitenutil.NewImageFromFileSystem(fsys, name)
+	return img, err
+}
+
+// imagePath resolves an image path written by tiled, which may use
+// windows separators, against the directory of the file it came from
+func imagePath(dir, imgPath string) string {
+	return path.Join(dir, strings.ReplaceAll(imgPath, "\\", "/"))
+}
+
+var _ Tileset = (*UniformTileset)(nil)
+var _ Tileset = (*DynTileset)(nil)
@@ -0,0 +1,194 @@
+package tileset
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+	"testing/fstest"
+)
+
+// pngFile returns a file holding a blank png of the given size
+func pngFile(t *testing.T, w, h int) *fstest.MapFile {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return &fstest.MapFile{Data: buf.Bytes()}
+}
+
+func TestGridCells(t *testing.T) {
+	tests := []struct {
+		name                               string
+		imageSize, tileSize, margin, space int
+		want                               int
+	}{
+		{"exact", 64, 16, 0, 0, 4},
+		{"partial tile left over", 70, 16, 0, 0, 4},
+		{"margin", 68, 16, 2, 0, 4},
+		{"margin cuts off the last tile", 66, 16, 2, 0, 3},
+		{"spacing", 67, 16, 0, 1, 4},
+		{"no spacing after the last tile", 66, 16, 0, 1, 3},
+		{"margin and spacing", 71, 16, 2, 1, 4},
+		{"margin, spacing and a partial column", 80, 16, 2, 1, 4},
+		{"smaller than a tile", 8, 16, 0, 0, 1},
+		{"empty", 0, 16, 0, 0, 1},
+	}
+	for _, test := range tests {
+		if got := gridCells(test.imageSize, test.tileSize, test.margin, test.space); got != test.want {
+			t.Errorf("%s: gridCells(%d, %d, %d, %d) = %d, want %d",
+				test.name, test.imageSize, test.tileSize, test.margin, test.space, got, test.want)
+		}
+	}
+}
+
+func TestUniformTileset(t *testing.T) {
+	// 16x16 tiles with a margin of 2 and spacing of 1: three columns and
+	// 10 pixels of a partial fourth one, two rows
+	fsys := fstest.MapFS{"tiles/grid.png": pngFile(t, 2+3*17+10, 2+2*17-1+2)}
+
+	tests := []struct {
+		name  string
+		json  *TilesetJSON
+		count int
+		tiles map[int]image.Rectangle
+	}{
+		{
+			name: "counts from the file",
+			json: &TilesetJSON{
+				Path: "grid.png", TileWidth: 16, TileHeight: 16, Margin: 2, Spacing: 1,
+				Columns: 3, TileCount: 6, ImageWidth: 63, ImageHeight: 37,
+			},
+			count: 6,
+			tiles: map[int]image.Rectangle{
+				0: image.Rect(2, 2, 18, 18),
+				1: image.Rect(19, 2, 35, 18),
+				2: image.Rect(36, 2, 52, 18),
+				3: image.Rect(2, 19, 18, 35),
+				5: image.Rect(36, 19, 52, 35),
+			},
+		},
+		{
+			// the file's image size wins over the image, which may not have
+			// been updated yet
+			name: "counts from the image size in the file",
+			json: &TilesetJSON{
+				Path: "grid.png", TileWidth: 16, TileHeight: 16, Margin: 2, Spacing: 1,
+				ImageWidth: 80, ImageHeight: 37,
+			},
+			count: 8,
+			tiles: map[int]image.Rectangle{
+				4: image.Rect(2, 19, 18, 35),
+				5: image.Rect(19, 19, 35, 35),
+			},
+		},
+		{
+			name: "counts from the image",
+			json: &TilesetJSON{
+				Path: "grid.png", TileWidth: 16, TileHeight: 16, Margin: 2, Spacing: 1,
+			},
+			count: 6,
+			tiles: map[int]image.Rectangle{
+				2: image.Rect(36, 2, 52, 18),
+				5: image.Rect(36, 19, 52, 35),
+			},
+		},
+		{
+			name: "fewer tiles than the grid holds",
+			json: &TilesetJSON{
+				Path: "grid.png", TileWidth: 16, TileHeight: 16, Margin: 2, Spacing: 1,
+				Columns: 3, TileCount: 4,
+			},
+			count: 4,
+			tiles: map[int]image.Rectangle{
+				3: image.Rect(2, 19, 18, 35),
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts, err := NewTilesetFromJSON(fsys, test.json, "tiles")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ts.TileCount() != test.count {
+				t.Errorf("TileCount = %d, want %d", ts.TileCount(), test.count)
+			}
+			for id, want := range test.tiles {
+				img, err := ts.Img(id)
+				if err != nil {
+					t.Errorf("Img(%d): %v", id, err)
+					continue
+				}
+				if img.Bounds() != want {
+					t.Errorf("Img(%d) covers %v, want %v", id, img.Bounds(), want)
+				}
+			}
+			for _, id := range []int{-1, test.count} {
+				if _, err := ts.Img(id); err == nil {
+					t.Errorf("Img(%d) found a tile past the end", id)
+				}
+			}
+		})
+	}
+}
+
+func TestImageCollection(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tiles/tree.png":  pngFile(t, 32, 48),
+		"tiles/rock.png":  pngFile(t, 16, 16),
+		"tiles/sheet.png": pngFile(t, 64, 64),
+	}
+	// ids have gaps, as when tiles are removed from a collection in tiled,
+	// and are listed out of order
+	tilesetJSON := &TilesetJSON{
+		Tiles: []*TileJSON{
+			{Id: 7, Path: "rock.png", Width: 16, Height: 16},
+			{Id: 2, Path: "tree.png", Width: 32, Height: 48},
+			{Id: 12, Path: "sheet.png", Width: 64, Height: 64, X: 16, Y: 32, SubWidth: 16, SubHeight: 24},
+		},
+	}
+	ts, err := NewTilesetFromJSON(fsys, tilesetJSON, "tiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ts.TileCount() != 13 {
+		t.Errorf("TileCount = %d, want one past the highest id", ts.TileCount())
+	}
+	if w, h := ts.TileSize(); w != 32 || h != 48 {
+		t.Errorf("TileSize = %d, %d, want the largest tile's 32, 48", w, h)
+	}
+
+	tests := []struct {
+		id   int
+		want image.Rectangle
+		ok   bool
+	}{
+		{id: 2, want: image.Rect(0, 0, 32, 48), ok: true},
+		{id: 7, want: image.Rect(0, 0, 16, 16), ok: true},
+		{id: 12, want: image.Rect(16, 32, 32, 56), ok: true},
+		{id: 0},
+		{id: 1},
+		{id: 3},
+		{id: 11},
+		{id: 13},
+	}
+	for _, test := range tests {
+		img, err := ts.Img(test.id)
+		if !test.ok {
+			if err == nil {
+				t.Errorf("Img(%d) found a tile, want an error for the missing id", test.id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Img(%d): %v", test.id, err)
+			continue
+		}
+		if img.Bounds() != test.want {
+			t.Errorf("Img(%d) covers %v, want %v", test.id, img.Bounds(), test.want)
+		}
+	}
+}
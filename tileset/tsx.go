@@ -0,0 +1,92 @@
+package tileset
+
+import (
+	"EndlessJourney/tiled"
+	"encoding/xml"
+)
+
+type tsxImage struct {
+	Source string `xml:"source,attr"`
+	Width  int    `xml:"width,attr"`
+	Height int    `xml:"height,attr"`
+}
+
+type tsxTile struct {
+	Id          int              `xml:"id,attr"`
+	X           int              `xml:"x,attr"`
+	Y           int              `xml:"y,attr"`
+	Width       int              `xml:"width,attr"`
+	Height      int              `xml:"height,attr"`
+	Image       *tsxImage        `xml:"image"`
+	Properties  tiled.Properties `xml:"properties"`
+	ObjectGroup *ObjectGroupJSON `xml:"objectgroup"`
+	Animation   []FrameJSON      `xml:"animation>frame"`
+}
+
+// TilesetXML is a tileset in tiled's xml format, either a tsx file or a
+// tileset embedded in a tmx map
+type TilesetXML struct {
+	TileCount       int                  `xml:"tilecount,attr"`
+	Columns         int                  `xml:"columns,attr"`
+	TileWidth       int                  `xml:"tilewidth,attr"`
+	TileHeight      int                  `xml:"tileheight,attr"`
+	Margin          int                  `xml:"margin,attr"`
+	Spacing         int                  `xml:"spacing,attr"`
+	TileOffset      *TileOffsetJSON      `xml:"tileoffset"`
+	Transformations *TransformationsJSON `xml:"transformations"`
+	Image           *tsxImage            `xml:"image"`
+	Tiles           []tsxTile            `xml:"tile"`
+}
+
+// parseTSX reads a tileset saved in tiled's xml format into the same
+// model the json loader produces
+func parseTSX(contents []byte) (*TilesetJSON, error) {
+	var tsx TilesetXML
+	err := xml.Unmarshal(contents, &tsx)
+	if err != nil {
+		return nil, err
+	}
+
+	return tsx.JSON(), nil
+}
+
+// JSON converts the tileset into the same model the json loader produces
+func (tsx *TilesetXML) JSON() *TilesetJSON {
+	tilesetJSON := TilesetJSON{
+		TileCount:       tsx.TileCount,
+		Columns:         tsx.Columns,
+		TileWidth:       tsx.TileWidth,
+		TileHeight:      tsx.TileHeight,
+		Margin:          tsx.Margin,
+		Spacing:         tsx.Spacing,
+		TileOffset:      tsx.TileOffset,
+		Tiles:           make([]*TileJSON, 0),
+		Transformations: tsx.Transformations,
+	}
+	if tsx.Image != nil {
+		tilesetJSON.Path = tsx.Image.Source
+		tilesetJSON.ImageWidth = tsx.Image.Width
+		tilesetJSON.ImageHeight = tsx.Image.Height
+	}
+
+	for _, tile := range tsx.Tiles {
+		tileJSON := TileJSON{
+			Id:          tile.Id,
+			X:           tile.X,
+			Y:           tile.Y,
+			SubWidth:    tile.Width,
+			SubHeight:   tile.Height,
+			Properties:  tile.Properties,
+			ObjectGroup: tile.ObjectGroup,
+			Animation:   tile.Animation,
+		}
+		if tile.Image != nil {
+			tileJSON.Path = tile.Image.Source
+			tileJSON.Width = tile.Image.Width
+			tileJSON.Height = tile.Image.Height
+		}
+		tilesetJSON.Tiles = append(tilesetJSON.Tiles, &tileJSON)
+	}
+
+	return &tilesetJSON
+}
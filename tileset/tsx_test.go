@@ -0,0 +1,70 @@
+package tileset
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const tsxFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" name="floor" tilewidth="16" tileheight="16" spacing="1" margin="2" tilecount="6" columns="3">
+ <tileoffset x="0" y="4"/>
+ <transformations hflip="1" vflip="0" rotate="1" preferuntransformed="0"/>
+ <image source="floor.png" width="55" height="38"/>
+ <tile id="0">
+  <properties>
+   <property name="footstep" value="grass"/>
+   <property name="solid" type="bool" value="false"/>
+  </properties>
+ </tile>
+ <tile id="4">
+  <objectgroup draworder="index" id="2">
+   <object id="1" x="2" y="8" width="12" height="8"/>
+  </objectgroup>
+  <animation>
+   <frame tileid="4" duration="200"/>
+   <frame tileid="5" duration="250"/>
+  </animation>
+ </tile>
+</tileset>
+`
+
+// jsonFixture is the tileset of tsxFixture as tiled saves it in json
+const jsonFixture = `{
+ "name": "floor", "tilewidth": 16, "tileheight": 16, "spacing": 1, "margin": 2, "tilecount": 6, "columns": 3,
+ "tileoffset": {"x": 0, "y": 4},
+ "transformations": {"hflip": true, "vflip": false, "rotate": true, "preferuntransformed": false},
+ "image": "floor.png", "imagewidth": 55, "imageheight": 38,
+ "tiles": [
+  {"id": 0, "properties": [
+   {"name": "footstep", "type": "string", "value": "grass"},
+   {"name": "solid", "type": "bool", "value": false}
+  ]},
+  {"id": 4,
+   "objectgroup": {"draworder": "index", "id": 2, "objects": [{"id": 1, "x": 2, "y": 8, "width": 12, "height": 8}]},
+   "animation": [{"tileid": 4, "duration": 200}, {"tileid": 5, "duration": 250}]}
+ ]
+}`
+
+func TestParseTSXMatchesJSON(t *testing.T) {
+	fromTSX, err := parseTSX([]byte(tsxFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON := &TilesetJSON{}
+	if err := json.Unmarshal([]byte(jsonFixture), fromJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(fromTSX, fromJSON) {
+		t.Errorf("tsx\n%+v\njson\n%+v", fromTSX, fromJSON)
+	}
+	if len(fromTSX.Tiles) != len(fromJSON.Tiles) {
+		t.Fatalf("tsx has %d tiles, json %d", len(fromTSX.Tiles), len(fromJSON.Tiles))
+	}
+	for index, tile := range fromTSX.Tiles {
+		if !reflect.DeepEqual(tile, fromJSON.Tiles[index]) {
+			t.Errorf("tile %d\ntsx  %+v\njson %+v", tile.Id, tile, fromJSON.Tiles[index])
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package worldgen
+
+// Biome describes how a region of the world looks and what lives there
+type Biome struct {
+	Name string
+	// local ids of the ground tiles in the TilesetFloor tileset, the
+	// first is the plain tile and the rest are rarer variations
+	Ground []int
+	// chance of a building in a chunk
+	Buildings float64
+	// most enemies in a chunk
+	Enemies int
+	// chance of a potion in a chunk
+	Potions float64
+}
+
+var (
+	Tundra = &Biome{
+		Name:      "tundra",
+		Ground:    []int{418, 419, 420, 421, 422},
+		Buildings: 0.05,
+		Enemies:   1,
+		Potions:   0.3,
+	}
+	Desert = &Biome{
+		Name:      "desert",
+		Ground:    []int{110, 111, 112, 113, 114},
+		Buildings: 0.1,
+		Enemies:   1,
+		Potions:   0.1,
+	}
+	Wasteland = &Biome{
+		Name:      "wasteland",
+		Ground:    []int{429, 430, 431, 432, 433},
+		Buildings: 0.05,
+		Enemies:   3,
+		Potions:   0.2,
+	}
+	Plains = &Biome{
+		Name:      "plains",
+		Ground:    []int{264, 265, 266, 267, 268},
+		Buildings: 0.3,
+		Enemies:   1,
+		Potions:   0.2,
+	}
+	Forest = &Biome{
+		Name:      "forest",
+		Ground:    []int{279, 275, 276, 277, 278},
+		Buildings: 0.1,
+		Enemies:   2,
+		Potions:   0.3,
+	}
+)
+
+// chooseBiome picks the biome for a temperature and a moisture, both
+// between -1 and 1
+func chooseBiome(temperature, moisture float64) *Biome {
+	switch {
+	case temperature < -0.35:
+		return Tundra
+	case temperature > 0.3 && moisture < 0:
+		return Desert
+	case moisture < -0.25:
+		return Wasteland
+	case moisture > 0.2:
+		return Forest
+	default:
+		return Plains
+	}
+}
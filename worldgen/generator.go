@@ -0,0 +1,243 @@
+package worldgen
+
+import (
+	"EndlessJourney/tiled"
+	"EndlessJourney/tilemap"
+	"errors"
+	"image"
+	"math/rand"
+)
+
+// tiles per side of a generated chunk
+const ChunkSize = 16
+
+// how many tiles wide the biome regions and their variations are
+const (
+	temperatureScale = 96.0
+	moistureScale    = 64.0
+)
+
+// building is a tile of the buildings tileset and the tiles it covers.
+// Its cell is the bottom left tile, the image reaches up and right.
+type building struct {
+	id            int
+	width, height int
+}
+
+var buildings = []building{
+	{id: 0, width: 4, height: 3},
+	{id: 1, width: 4, height: 3},
+	{id: 2, width: 3, height: 3},
+}
+
+// Generator makes the terrain, buildings and spawns of the endless world.
+// Chunks only depend on the seed and their position, so the same seed
+// always gives the same world whatever order it is explored in.
+type Generator struct {
+	Seed int64
+	// chunks this close to the origin get no buildings or enemies, so the
+	// player doesn't start inside a wall or a fight
+	SafeRadius   int
+	floorGID     int
+	buildingsGID int
+	tileWidth    int
+	tileHeight   int
+	temperature  *Noise
+	moisture     *Noise
+	landmarks    []*Landmark
+}
+
+// NewGenerator makes a generator for a map, which has to use the
+// TilesetFloor and buildings tilesets
+func NewGenerator(seed int64, tm *tilemap.TilemapJSON) (*Generator, error) {
+	floorGID, ok := tm.FirstGID("TilesetFloor")
+	if !ok {
+		return nil, errors.New("worldgen: map has no TilesetFloor tileset")
+	}
+	buildingsGID, ok := tm.FirstGID("buildings")
+	if !ok {
+		return nil, errors.New("worldgen: map has no buildings tileset")
+	}
+	tileWidth, tileHeight := tm.TileSize()
+
+	return &Generator{
+		Seed:         seed,
+		SafeRadius:   1,
+		floorGID:     floorGID,
+		buildingsGID: buildingsGID,
+		tileWidth:    tileWidth,
+		tileHeight:   tileHeight,
+		temperature:  NewNoise(seed),
+		moisture:     NewNoise(seed + 1),
+	}, nil
+}
+
+// AddLandmark places a hand made map in the world. Chunks that are
+// already generated don't change.
+func (g *Generator) AddLandmark(landmark *Landmark) {
+	g.landmarks = append(g.landmarks, landmark)
+}
+
+// Objects returns the objects of every landmark that are not spawned by
+// chunks, in world pixels
+func (g *Generator) Objects() []tiled.Object {
+	objects := make([]tiled.Object, 0)
+	for _, landmark := range g.landmarks {
+		objects = append(objects, landmark.Objects()...)
+	}
+	return objects
+}
+
+// Chunk is the generated content of a square of the world
+type Chunk struct {
+	// position in chunks
+	X, Y int
+	// biome at the center of the chunk
+	Biome   *Biome
+	Ground  *tilemap.Chunk
+	Objects *tilemap.Chunk
+	// enemies and potions, placed in pixels like map objects
+	Spawns []tiled.Object
+}
+
+// Bounds returns the area of the chunk in tiles
+func (c *Chunk) Bounds() image.Rectangle {
+	return image.Rect(c.X*ChunkSize, c.Y*ChunkSize, (c.X+1)*ChunkSize, (c.Y+1)*ChunkSize)
+}
+
+// Biome returns the biome at a tile position
+func (g *Generator) Biome(x, y int) *Biome {
+	return chooseBiome(
+		g.temperature.Fractal(float64(x)/temperatureScale, float64(y)/temperatureScale, 3),
+		g.moisture.Fractal(float64(x)/moistureScale, float64(y)/moistureScale, 3),
+	)
+}
+
+// Generate makes the chunk at a chunk position
+func (g *Generator) Generate(cx, cy int) *Chunk {
+	chunk := &Chunk{
+		X:      cx,
+		Y:      cy,
+		Spawns: make([]tiled.Object, 0),
+	}
+	bounds := chunk.Bounds()
+	chunk.Ground = newTileChunk(bounds)
+	chunk.Objects = newTileChunk(bounds)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			index := (y-bounds.Min.Y)*ChunkSize + (x - bounds.Min.X)
+			chunk.Ground.Data[index] = g.floorGID + g.groundTile(g.Biome(x, y), x, y)
+			for _, landmark := range g.landmarks {
+				if landmark.paint(chunk, index, x, y, g.Seed) {
+					break
+				}
+			}
+		}
+	}
+	chunk.Biome = g.Biome(bounds.Min.X+ChunkSize/2, bounds.Min.Y+ChunkSize/2)
+
+	//landmarks bring their own content, generated content stays out of
+	//them and their edges
+	taken := make([]image.Rectangle, 0)
+	area := image.Rect(
+		bounds.Min.X*g.tileWidth,
+		bounds.Min.Y*g.tileHeight,
+		bounds.Max.X*g.tileWidth,
+		bounds.Max.Y*g.tileHeight,
+	)
+	for _, landmark := range g.landmarks {
+		chunk.Spawns = append(chunk.Spawns, landmark.spawnsIn(area)...)
+		covered := landmark.Bounds.Inset(-blendMargin).Intersect(bounds)
+		if !covered.Empty() {
+			taken = append(taken, covered.Sub(bounds.Min))
+		}
+	}
+
+	if cx*cx+cy*cy <= g.SafeRadius*g.SafeRadius {
+		return chunk
+	}
+
+	r := rand.New(rand.NewSource(int64(hash(g.Seed, cx, cy))))
+
+	if r.Float64() < chunk.Biome.Buildings {
+		b := buildings[r.Intn(len(buildings))]
+		x := r.Intn(ChunkSize - b.width + 1)
+		y := b.height - 1 + r.Intn(ChunkSize-b.height+1)
+		//keep the row in front of the door free as well
+		footprint := image.Rect(x, y-b.height+1, x+b.width, y+2)
+		if !overlapsAny(footprint, taken) {
+			chunk.Objects.Data[y*ChunkSize+x] = g.buildingsGID + b.id
+			taken = append(taken, footprint)
+		}
+	}
+
+	enemies := r.Intn(chunk.Biome.Enemies + 1)
+	for i := 0; i < enemies; i++ {
+		if spot, ok := freeSpot(r, &taken); ok {
+			chunk.Spawns = append(chunk.Spawns, g.spawn("enemy", bounds.Min.Add(spot)))
+		}
+	}
+	if r.Float64() < chunk.Biome.Potions {
+		if spot, ok := freeSpot(r, &taken); ok {
+			chunk.Spawns = append(chunk.Spawns, g.spawn("potion", bounds.Min.Add(spot)))
+		}
+	}
+
+	return chunk
+}
+
+// groundTile picks the local id of the ground tile at a tile position,
+// mostly the biome's plain tile with the odd variation
+func (g *Generator) groundTile(biome *Biome, x, y int) int {
+	h := hash(g.Seed, x, y)
+	if h%100 < 85 || len(biome.Ground) == 1 {
+		return biome.Ground[0]
+	}
+	return biome.Ground[1+int(h/100%uint64(len(biome.Ground)-1))]
+}
+
+// spawn makes a point object of the given class at a tile position
+func (g *Generator) spawn(class string, tile image.Point) tiled.Object {
+	return tiled.Object{
+		Class:   class,
+		X:       float64(tile.X * g.tileWidth),
+		Y:       float64(tile.Y * g.tileHeight),
+		Visible: true,
+		Shape:   tiled.Point,
+	}
+}
+
+// freeSpot picks a tile of the chunk nothing has been put on yet and
+// marks it as taken, giving up after a few tries
+func freeSpot(r *rand.Rand, taken *[]image.Rectangle) (image.Point, bool) {
+	for try := 0; try < 8; try++ {
+		spot := image.Pt(r.Intn(ChunkSize), r.Intn(ChunkSize))
+		rect := image.Rect(spot.X, spot.Y, spot.X+1, spot.Y+1)
+		if !overlapsAny(rect, *taken) {
+			*taken = append(*taken, rect)
+			return spot, true
+		}
+	}
+	return image.Point{}, false
+}
+
+func overlapsAny(rect image.Rectangle, rects []image.Rectangle) bool {
+	for _, other := range rects {
+		if rect.Overlaps(other) {
+			return true
+		}
+	}
+	return false
+}
+
+func newTileChunk(bounds image.Rectangle) *tilemap.Chunk {
+	return &tilemap.Chunk{
+		X:      bounds.Min.X,
+		Y:      bounds.Min.Y,
+		Width:  bounds.Dx(),
+		Height: bounds.Dy(),
+		Data:   make([]int, bounds.Dx()*bounds.Dy()),
+		Flips:  make([]tilemap.Flip, bounds.Dx()*bounds.Dy()),
+	}
+}
@@ -0,0 +1,183 @@
+package worldgen
+
+import (
+	"EndlessJourney/tilemap"
+	"image"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// testWorld returns an infinite map with the tilesets the generator needs
+func testWorld() *tilemap.TilemapJSON {
+	return &tilemap.TilemapJSON{
+		Tilesets: []tilemap.TilesetRefJSON{
+			{FirstGID: 1, Source: "tilesets/TilesetFloor.json"},
+			{FirstGID: 2000, Source: "tilesets/buildings.json"},
+		},
+		TileWidth:  16,
+		TileHeight: 16,
+		Infinite:   true,
+	}
+}
+
+func newTestGenerator(t *testing.T, seed int64) *Generator {
+	t.Helper()
+	gen, err := NewGenerator(seed, testWorld())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gen
+}
+
+func TestNewGeneratorNeedsTilesets(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources []string
+		wantErr bool
+	}{
+		{"both", []string{"tilesets/TilesetFloor.json", "tilesets/buildings.json"}, false},
+		{"no floor", []string{"tilesets/buildings.json"}, true},
+		{"no buildings", []string{"tilesets/TilesetFloor.json"}, true},
+		{"none", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			world := &tilemap.TilemapJSON{}
+			for index, source := range test.sources {
+				world.Tilesets = append(world.Tilesets, tilemap.TilesetRefJSON{FirstGID: 1 + index*1000, Source: source})
+			}
+			_, err := NewGenerator(1, world)
+			if (err != nil) != test.wantErr {
+				t.Errorf("NewGenerator error = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	chunks := []image.Point{{0, 0}, {3, -2}, {-5, -5}, {12, 40}}
+	for _, seed := range []int64{0, 1, 42, -7} {
+		first := newTestGenerator(t, seed)
+		second := newTestGenerator(t, seed)
+		// generate in a different order, chunks must not depend on it
+		for index := len(chunks) - 1; index >= 0; index-- {
+			second.Generate(chunks[index].X, chunks[index].Y)
+		}
+		for _, position := range chunks {
+			a := first.Generate(position.X, position.Y)
+			b := second.Generate(position.X, position.Y)
+			if !reflect.DeepEqual(a, b) {
+				t.Errorf("seed %d: chunk %v differs between generators", seed, position)
+			}
+		}
+	}
+}
+
+func TestGenerateSeedsDiffer(t *testing.T) {
+	a := newTestGenerator(t, 1).Generate(4, 4)
+	b := newTestGenerator(t, 2).Generate(4, 4)
+	if reflect.DeepEqual(a.Ground.Data, b.Ground.Data) {
+		t.Error("seeds 1 and 2 generated the same ground")
+	}
+}
+
+func TestGenerateChunkLayout(t *testing.T) {
+	gen := newTestGenerator(t, 3)
+	tests := []struct {
+		cx, cy int
+		bounds image.Rectangle
+	}{
+		{0, 0, image.Rect(0, 0, 16, 16)},
+		{1, -1, image.Rect(16, -16, 32, 0)},
+		{-2, 3, image.Rect(-32, 48, -16, 64)},
+	}
+	for _, test := range tests {
+		chunk := gen.Generate(test.cx, test.cy)
+		if chunk.Bounds() != test.bounds {
+			t.Errorf("chunk %d,%d bounds = %v, want %v", test.cx, test.cy, chunk.Bounds(), test.bounds)
+		}
+		if chunk.Ground.Bounds() != test.bounds || chunk.Objects.Bounds() != test.bounds {
+			t.Errorf("chunk %d,%d layers cover %v and %v, want %v",
+				test.cx, test.cy, chunk.Ground.Bounds(), chunk.Objects.Bounds(), test.bounds)
+		}
+		for index, gid := range chunk.Ground.Data {
+			x := test.bounds.Min.X + index%ChunkSize
+			y := test.bounds.Min.Y + index/ChunkSize
+			biome := gen.Biome(x, y)
+			if !slices.Contains(biome.Ground, gid-1) {
+				t.Errorf("ground at %d,%d is local tile %d, not one of the %s tiles", x, y, gid-1, biome.Name)
+				break
+			}
+		}
+	}
+}
+
+func TestSafeRadius(t *testing.T) {
+	gen := newTestGenerator(t, 5)
+	for cy := -gen.SafeRadius; cy <= gen.SafeRadius; cy++ {
+		for cx := -gen.SafeRadius; cx <= gen.SafeRadius; cx++ {
+			if cx*cx+cy*cy > gen.SafeRadius*gen.SafeRadius {
+				continue
+			}
+			chunk := gen.Generate(cx, cy)
+			if len(chunk.Spawns) != 0 {
+				t.Errorf("chunk %d,%d near the start has %d spawns", cx, cy, len(chunk.Spawns))
+			}
+			for _, gid := range chunk.Objects.Data {
+				if gid != 0 {
+					t.Errorf("chunk %d,%d near the start has a building", cx, cy)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestGenerateBuildings(t *testing.T) {
+	gen := newTestGenerator(t, 9)
+	found := 0
+	for cy := -8; cy <= 8; cy++ {
+		for cx := -8; cx <= 8; cx++ {
+			for index, gid := range gen.Generate(cx, cy).Objects.Data {
+				if gid == 0 {
+					continue
+				}
+				id := gid - 2000
+				if id < 0 || id >= len(buildings) {
+					t.Fatalf("chunk %d,%d has objects gid %d outside the buildings tileset", cx, cy, gid)
+				}
+				// the building grows up and right from its cell
+				x, y := index%ChunkSize, index/ChunkSize
+				if x+buildings[id].width > ChunkSize || y-buildings[id].height+1 < 0 {
+					t.Errorf("chunk %d,%d building %d at %d,%d sticks out of the chunk", cx, cy, id, x, y)
+				}
+				found++
+			}
+		}
+	}
+	if found == 0 {
+		t.Error("no chunk got a building")
+	}
+}
+
+func TestChooseBiome(t *testing.T) {
+	tests := []struct {
+		temperature, moisture float64
+		want                  *Biome
+	}{
+		{-1, 0, Tundra},
+		{-0.5, 1, Tundra},
+		{0.5, -0.5, Desert},
+		{0.5, 0.5, Forest},
+		{0, -0.5, Wasteland},
+		{0, 0.5, Forest},
+		{0, 0, Plains},
+		{0.3, -0.1, Plains},
+	}
+	for _, test := range tests {
+		if got := chooseBiome(test.temperature, test.moisture); got != test.want {
+			t.Errorf("chooseBiome(%v, %v) = %s, want %s", test.temperature, test.moisture, got.Name, test.want.Name)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package worldgen
+
+import (
+	"EndlessJourney/tiled"
+	"EndlessJourney/tilemap"
+	"fmt"
+	"image"
+	"math"
+)
+
+// how many tiles around a landmark its ground blends into the terrain
+const blendMargin = 4
+
+// Landmark is a hand made map, such as a town or a dungeon, placed in the
+// generated world. Its tiles replace the terrain, and the terrain around
+// it fades into its most common ground tile.
+type Landmark struct {
+	// area of the world the landmark covers, in tiles
+	Bounds      image.Rectangle
+	ground      []int
+	groundFlips []tilemap.Flip
+	objects     []int
+	objectFlips []tilemap.Flip
+	// ground tile for empty cells and the blended edge
+	fill int
+	// enemies and potions, spawned by the chunks they stand in
+	spawns []tiled.Object
+	// every other object, such as colliders, portals and spawn points
+	statics []tiled.Object
+}
+
+// NewLandmark places the map m in the world map with its top left tile at
+// position. The tiles of the layer at objectsLayer go to the world's
+// objects layer and every other tile layer is flattened into the ground.
+// Tilesets are matched by name, so the world needs every tileset m uses.
+func NewLandmark(world, m *tilemap.TilemapJSON, position image.Point, objectsLayer int) (*Landmark, error) {
+	worldWidth, worldHeight := world.TileSize()
+	tileWidth, tileHeight := m.TileSize()
+	if tileWidth != worldWidth || tileHeight != worldHeight {
+		return nil, fmt.Errorf(
+			"worldgen: landmark tiles are %dx%d, the world's are %dx%d",
+			tileWidth, tileHeight, worldWidth, worldHeight,
+		)
+	}
+
+	bounds := m.Bounds()
+	offset := position.Sub(bounds.Min)
+	size := bounds.Dx() * bounds.Dy()
+	l := &Landmark{
+		Bounds:      bounds.Add(offset),
+		ground:      make([]int, size),
+		groundFlips: make([]tilemap.Flip, size),
+		objects:     make([]int, size),
+		objectFlips: make([]tilemap.Flip, size),
+		spawns:      make([]tiled.Object, 0),
+		statics:     make([]tiled.Object, 0),
+	}
+
+	var err error
+	counts := make(map[int]int)
+	for index, layer := range m.Layers {
+		if layer.Type != tilemap.TileLayer {
+			continue
+		}
+		gids, flips := l.ground, l.groundFlips
+		if index == objectsLayer {
+			gids, flips = l.objects, l.objectFlips
+		}
+		layer.ForEachTile(func(x, y, gid int, flip tilemap.Flip) {
+			worldGID, gidErr := remapGID(world, m, gid)
+			if gidErr != nil {
+				if err == nil {
+					err = fmt.Errorf("worldgen: landmark layer %q at %d,%d: %w", layer.Name, x, y, gidErr)
+				}
+				return
+			}
+			cell := (y-bounds.Min.Y)*bounds.Dx() + (x - bounds.Min.X)
+			gids[cell] = worldGID
+			flips[cell] = flip
+			if index != objectsLayer {
+				counts[worldGID]++
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for gid, count := range counts {
+		if count > counts[l.fill] || (count == counts[l.fill] && gid < l.fill) {
+			l.fill = gid
+		}
+	}
+
+	for _, object := range m.Objects() {
+		object.X += float64(offset.X * tileWidth)
+		object.Y += float64(offset.Y * tileHeight)
+		if object.Class == "enemy" || object.Class == "potion" {
+			l.spawns = append(l.spawns, object)
+		} else {
+			l.statics = append(l.statics, object)
+		}
+	}
+
+	return l, nil
+}
+
+// Objects returns the objects of the landmark that are not spawned by
+// chunks, in world pixels
+func (l *Landmark) Objects() []tiled.Object {
+	return l.statics
+}
+
+// paint writes the landmark's tiles at a tile position of a chunk, or
+// the fill tile if the position is on the blended edge. It reports
+// whether the position was painted.
+func (l *Landmark) paint(chunk *Chunk, index, x, y int, seed int64) bool {
+	if image.Pt(x, y).In(l.Bounds) {
+		cell := (y-l.Bounds.Min.Y)*l.Bounds.Dx() + (x - l.Bounds.Min.X)
+		if l.ground[cell] != 0 {
+			chunk.Ground.Data[index] = l.ground[cell]
+			chunk.Ground.Flips[index] = l.groundFlips[cell]
+		} else if l.fill != 0 {
+			chunk.Ground.Data[index] = l.fill
+		}
+		chunk.Objects.Data[index] = l.objects[cell]
+		chunk.Objects.Flips[index] = l.objectFlips[cell]
+		return true
+	}
+
+	if l.fill == 0 {
+		return false
+	}
+
+	//the further from the landmark the fewer tiles take its ground
+	distance := max(
+		l.Bounds.Min.X-x, x-(l.Bounds.Max.X-1),
+		l.Bounds.Min.Y-y, y-(l.Bounds.Max.Y-1),
+	)
+	if distance > blendMargin || int(hash(seed, x, y, 1)%(blendMargin+1)) < distance {
+		return false
+	}
+	chunk.Ground.Data[index] = l.fill
+	return true
+}
+
+// spawnsIn returns the landmark's spawns standing in an area, in pixels
+func (l *Landmark) spawnsIn(area image.Rectangle) []tiled.Object {
+	spawns := make([]tiled.Object, 0)
+	for _, object := range l.spawns {
+		position := image.Pt(int(math.Floor(object.X)), int(math.Floor(object.Y)))
+		if position.In(area) {
+			spawns = append(spawns, object)
+		}
+	}
+	return spawns
+}
+
+// remapGID turns a gid of m into the gid of the same tile in world
+func remapGID(world, m *tilemap.TilemapJSON, gid int) (int, error) {
+	tilesetRef, ok := m.TilesetRef(gid)
+	if !ok {
+		return 0, fmt.Errorf("no tileset for gid %d", gid)
+	}
+	name := tilesetRef.Name()
+	if name == "" {
+		return 0, fmt.Errorf("gid %d is in an embedded tileset", gid)
+	}
+	firstGID, ok := world.FirstGID(name)
+	if !ok {
+		return 0, fmt.Errorf("the world has no %s tileset", name)
+	}
+	return firstGID + gid - tilesetRef.FirstGID, nil
+}
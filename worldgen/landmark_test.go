@@ -0,0 +1,176 @@
+package worldgen
+
+import (
+	"EndlessJourney/tiled"
+	"EndlessJourney/tilemap"
+	"image"
+	"testing"
+)
+
+// testLandmark returns a 3x2 map with a ground layer, an objects layer
+// holding one building and an object group with an enemy and a portal
+func testLandmark() *tilemap.TilemapJSON {
+	return &tilemap.TilemapJSON{
+		Tilesets: []tilemap.TilesetRefJSON{
+			{FirstGID: 1, Source: "../tilesets/TilesetFloor.json"},
+			{FirstGID: 500, Source: "buildings.tsx"},
+		},
+		Width:      3,
+		Height:     2,
+		TileWidth:  16,
+		TileHeight: 16,
+		Layers: []tilemap.TilemapLayerJSON{
+			{
+				Name: "ground",
+				Type: tilemap.TileLayer,
+				Chunks: []*tilemap.Chunk{{
+					Width:  3,
+					Height: 2,
+					Data:   []int{5, 5, 5, 5, 6, 0},
+					Flips:  []tilemap.Flip{0, 0, 0, 0, tilemap.FlipHorizontal, 0},
+				}},
+			},
+			{
+				Name: "objects",
+				Type: tilemap.TileLayer,
+				Chunks: []*tilemap.Chunk{{
+					Width:  3,
+					Height: 2,
+					Data:   []int{0, 501, 0, 0, 0, 0},
+					Flips:  make([]tilemap.Flip, 6),
+				}},
+			},
+			{
+				Name: "spawns",
+				Type: tilemap.ObjectGroup,
+				Objects: []tiled.Object{
+					{Class: "enemy", X: 8, Y: 8},
+					{Class: "portal", X: 32, Y: 16},
+				},
+			},
+		},
+	}
+}
+
+func TestLandmarkPlacement(t *testing.T) {
+	world := testWorld()
+	position := image.Pt(20, 4)
+	landmark, err := NewLandmark(world, testLandmark(), position, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := image.Rect(20, 4, 23, 6); landmark.Bounds != want {
+		t.Fatalf("Bounds = %v, want %v", landmark.Bounds, want)
+	}
+
+	gen := newTestGenerator(t, 11)
+	gen.AddLandmark(landmark)
+	chunk := gen.Generate(1, 0)
+
+	tests := []struct {
+		name    string
+		x, y    int
+		ground  int
+		flip    tilemap.Flip
+		objects int
+	}{
+		{name: "ground", x: 20, y: 4, ground: 5},
+		{name: "flipped ground", x: 21, y: 5, ground: 6, flip: tilemap.FlipHorizontal},
+		{name: "empty cell takes the fill", x: 22, y: 5, ground: 5},
+		{name: "building", x: 21, y: 4, ground: 5, objects: 2001},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			index := (test.y-chunk.Ground.Y)*ChunkSize + (test.x - chunk.Ground.X)
+			if gid := chunk.Ground.Data[index]; gid != test.ground {
+				t.Errorf("ground gid = %d, want %d", gid, test.ground)
+			}
+			if flip := chunk.Ground.Flips[index]; flip != test.flip {
+				t.Errorf("ground flip = %b, want %b", flip, test.flip)
+			}
+			if gid := chunk.Objects.Data[index]; gid != test.objects {
+				t.Errorf("objects gid = %d, want %d", gid, test.objects)
+			}
+		})
+	}
+
+	// the fill tile only blends into the terrain near the landmark
+	for y := 0; y < ChunkSize; y++ {
+		for x := 0; x < ChunkSize; x++ {
+			tile := image.Pt(chunk.Ground.X+x, chunk.Ground.Y+y)
+			near := landmark.Bounds.Inset(-blendMargin)
+			if !tile.In(near) && chunk.Ground.Data[y*ChunkSize+x] == 5 {
+				t.Errorf("tile %v is over %d tiles away but has the landmark's ground", tile, blendMargin)
+			}
+		}
+	}
+
+	if len(chunk.Spawns) != 1 || chunk.Spawns[0].Class != "enemy" {
+		t.Fatalf("chunk spawns = %v, want the landmark's enemy", chunk.Spawns)
+	}
+	if x, y := chunk.Spawns[0].X, chunk.Spawns[0].Y; x != 20*16+8 || y != 4*16+8 {
+		t.Errorf("enemy at %v,%v, want it moved with the landmark", x, y)
+	}
+	if spawns := gen.Generate(0, 0).Spawns; len(spawns) != 0 {
+		t.Errorf("chunk 0,0 spawns = %v, want the enemy only in its own chunk", spawns)
+	}
+
+	statics := gen.Objects()
+	if len(statics) != 1 || statics[0].Class != "portal" {
+		t.Fatalf("Objects = %v, want the landmark's portal", statics)
+	}
+	if x, y := statics[0].X, statics[0].Y; x != 20*16+32 || y != 4*16+16 {
+		t.Errorf("portal at %v,%v, want it moved with the landmark", x, y)
+	}
+}
+
+func TestNewLandmarkErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(world, m *tilemap.TilemapJSON)
+	}{
+		{"tile size", func(world, m *tilemap.TilemapJSON) {
+			m.TileWidth = 32
+		}},
+		{"tileset missing from the world", func(world, m *tilemap.TilemapJSON) {
+			world.Tilesets = world.Tilesets[:1]
+		}},
+		{"embedded tileset", func(world, m *tilemap.TilemapJSON) {
+			m.Tilesets[1].Source = ""
+		}},
+		{"gid before every tileset", func(world, m *tilemap.TilemapJSON) {
+			m.Tilesets[0].FirstGID = 10
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			world, m := testWorld(), testLandmark()
+			test.change(world, m)
+			if _, err := NewLandmark(world, m, image.Pt(0, 0), 1); err == nil {
+				t.Error("NewLandmark succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestRemapGID(t *testing.T) {
+	world, m := testWorld(), testLandmark()
+	tests := []struct {
+		gid, want int
+	}{
+		{1, 1},
+		{499, 499},
+		{500, 2000},
+		{502, 2002},
+	}
+	for _, test := range tests {
+		got, err := remapGID(world, m, test.gid)
+		if err != nil {
+			t.Errorf("remapGID(%d): %v", test.gid, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("remapGID(%d) = %d, want %d", test.gid, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package worldgen
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Noise is seeded two dimensional gradient noise. Values change smoothly
+// between nearby positions and lie roughly between -1 and 1.
+type Noise struct {
+	perm [512]int
+}
+
+func NewNoise(seed int64) *Noise {
+	n := &Noise{}
+	p := rand.New(rand.NewSource(seed)).Perm(256)
+	for index := range n.perm {
+		n.perm[index] = p[index%256]
+	}
+	return n
+}
+
+// At returns the noise at a position, whole numbers are one lattice cell
+// apart
+func (n *Noise) At(x, y float64) float64 {
+	x0 := math.Floor(x)
+	y0 := math.Floor(y)
+	fx := x - x0
+	fy := y - y0
+	ix := int(x0) & 255
+	iy := int(y0) & 255
+
+	//gradients of the four corners of the cell
+	g00 := n.gradient(n.perm[ix+n.perm[iy]], fx, fy)
+	g10 := n.gradient(n.perm[ix+1+n.perm[iy]], fx-1, fy)
+	g01 := n.gradient(n.perm[ix+n.perm[iy+1]], fx, fy-1)
+	g11 := n.gradient(n.perm[ix+1+n.perm[iy+1]], fx-1, fy-1)
+
+	u := fade(fx)
+	v := fade(fy)
+	return lerp(lerp(g00, g10, u), lerp(g01, g11, u), v) * math.Sqrt2
+}
+
+// Fractal sums octaves of the noise, each at twice the frequency and half
+// the weight of the one before, for detail at several scales
+func (n *Noise) Fractal(x, y float64, octaves int) float64 {
+	sum := 0.0
+	weight := 1.0
+	total := 0.0
+	for octave := 0; octave < octaves; octave++ {
+		sum += n.At(x, y) * weight
+		total += weight
+		weight /= 2
+		x *= 2
+		y *= 2
+	}
+	return sum / total
+}
+
+// gradient picks one of eight directions and returns its dot product with
+// the offset from the corner
+func (n *Noise) gradient(hash int, x, y float64) float64 {
+	switch hash & 7 {
+	case 0:
+		return x + y
+	case 1:
+		return x - y
+	case 2:
+		return -x + y
+	case 3:
+		return -x - y
+	case 4:
+		return x
+	case 5:
+		return -x
+	case 6:
+		return y
+	default:
+		return -y
+	}
+}
+
+// fade eases the position within a cell so cell borders don't show
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
+
+// hash mixes a seed and a few numbers into a well spread value, for
+// choices that have to come out the same whatever order chunks load in
+func hash(seed int64, values ...int) uint64 {
+	h := uint64(seed)
+	for _, value := range values {
+		h ^= uint64(value) + 0x9e3779b97f4a7c15 + (h << 6) + (h >> 2)
+		//splitmix64 finalizer
+		h ^= h >> 30
+		h *= 0xbf58476d1ce4e5b9
+		h ^= h >> 27
+		h *= 0x94d049bb133111eb
+		h ^= h >> 31
+	}
+	return h
+}
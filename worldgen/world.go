@@ -0,0 +1,112 @@
+package worldgen
+
+import (
+	"EndlessJourney/tiled"
+	"EndlessJourney/tilemap"
+	"image"
+)
+
+// World streams generated chunks into the ground and objects layers of
+// an infinite map, keeping only the chunks around the view loaded
+type World struct {
+	// chunks loaded beyond the edges of the view
+	Radius       int
+	gen          *Generator
+	tilemap      *tilemap.TilemapJSON
+	groundLayer  int
+	objectsLayer int
+	loaded       map[image.Point]*Chunk
+}
+
+func NewWorld(gen *Generator, tm *tilemap.TilemapJSON, groundLayer, objectsLayer int) *World {
+	return &World{
+		Radius:       1,
+		gen:          gen,
+		tilemap:      tm,
+		groundLayer:  groundLayer,
+		objectsLayer: objectsLayer,
+		loaded:       make(map[image.Point]*Chunk),
+	}
+}
+
+// Update loads the chunks near the view, given in pixels, and unloads
+// those that have gone further than a chunk past the loading distance.
+// It returns the chunks it loaded and unloaded so that their spawns and
+// cached images can be dealt with.
+func (w *World) Update(view image.Rectangle) (loaded, unloaded []*Chunk) {
+	near := w.chunksAround(view, w.Radius)
+	far := w.chunksAround(view, w.Radius+1)
+
+	for position, chunk := range w.loaded {
+		if !position.In(far) {
+			w.unload(chunk)
+			unloaded = append(unloaded, chunk)
+		}
+	}
+
+	for y := near.Min.Y; y < near.Max.Y; y++ {
+		for x := near.Min.X; x < near.Max.X; x++ {
+			if _, ok := w.loaded[image.Pt(x, y)]; ok {
+				continue
+			}
+			chunk := w.gen.Generate(x, y)
+			w.load(chunk)
+			loaded = append(loaded, chunk)
+		}
+	}
+	return loaded, unloaded
+}
+
+// Objects returns the objects of the world's landmarks that are not
+// spawned by chunks, in world pixels
+func (w *World) Objects() []tiled.Object {
+	return w.gen.Objects()
+}
+
+// UnloadAll unloads every chunk, returning them
+func (w *World) UnloadAll() []*Chunk {
+	unloaded := make([]*Chunk, 0, len(w.loaded))
+	for _, chunk := range w.loaded {
+		w.unload(chunk)
+		unloaded = append(unloaded, chunk)
+	}
+	return unloaded
+}
+
+func (w *World) load(chunk *Chunk) {
+	w.tilemap.Layers[w.groundLayer].SetChunk(chunk.Ground)
+	if w.objectsLayer >= 0 {
+		w.tilemap.Layers[w.objectsLayer].SetChunk(chunk.Objects)
+	}
+	w.loaded[image.Pt(chunk.X, chunk.Y)] = chunk
+}
+
+func (w *World) unload(chunk *Chunk) {
+	w.tilemap.Layers[w.groundLayer].RemoveChunk(chunk.Ground.X, chunk.Ground.Y)
+	if w.objectsLayer >= 0 {
+		w.tilemap.Layers[w.objectsLayer].RemoveChunk(chunk.Objects.X, chunk.Objects.Y)
+	}
+	delete(w.loaded, image.Pt(chunk.X, chunk.Y))
+}
+
+// chunksAround returns the chunk positions covering the view and radius
+// more chunks in every direction
+func (w *World) chunksAround(view image.Rectangle, radius int) image.Rectangle {
+	tileWidth, tileHeight := w.tilemap.TileSize()
+	chunkWidth, chunkHeight := ChunkSize*tileWidth, ChunkSize*tileHeight
+	return image.Rect(
+		floorDiv(view.Min.X, chunkWidth)-radius,
+		floorDiv(view.Min.Y, chunkHeight)-radius,
+		floorDiv(view.Max.X-1, chunkWidth)+radius+1,
+		floorDiv(view.Max.Y-1, chunkHeight)+radius+1,
+	)
+}
+
+// floorDiv divides rounding towards negative infinity
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}